@@ -20,6 +20,11 @@ type RegisterRequest struct {
 	LastName  string `json:"last_name" binding:"required"`
 	Phone     string `json:"phone,omitempty"`
 	Role      string `json:"role" binding:"required,oneof=customer business_owner staff admin"`
+
+	// AcceptedTermsVersion must match the currently active
+	// TermsOfService.Version when one is configured; registration is
+	// rejected otherwise.
+	AcceptedTermsVersion string `json:"accepted_terms_version,omitempty"`
 }
 
 // LoginResponse represents successful login response
@@ -30,6 +35,12 @@ type LoginResponse struct {
 	ExpiresIn    int64     `json:"expires_in"` // Seconds until expiration
 	ExpiresAt    time.Time `json:"expires_at"`
 	User         user.User `json:"user"`
+
+	// TermsAcceptanceRequired is set when the active TermsOfService has no
+	// matching UserTermsAcceptance for this user, so the client knows to
+	// prompt for acceptance (via AcceptTermsRequest) even though login
+	// itself succeeded.
+	TermsAcceptanceRequired *user.TermsOfService `json:"terms_acceptance_required,omitempty"`
 }
 
 // RefreshTokenRequest represents refresh token request
@@ -55,6 +66,126 @@ type ChangePasswordRequest struct {
 	NewPassword     string `json:"new_password" binding:"required,min=8"`
 }
 
+// ConfirmEmailRequest confirms an email-confirmation or email-change token
+// (see user.UserToken)
+type ConfirmEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RequestEmailChangeRequest starts an email change for the authenticated
+// user; NewEmail is confirmed later via ConfirmEmailRequest
+type RequestEmailChangeRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+}
+
+// MFAEnrollResponse represents a freshly generated TOTP enrollment, returned
+// once so the user can scan the QR code and store the recovery codes
+type MFAEnrollResponse struct {
+	Secret        string   `json:"secret"`
+	OTPAuthURL    string   `json:"otpauth_url"`
+	QRCodeDataURL string   `json:"qr_code_data_url"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAVerifyRequest confirms a TOTP enrollment or disables MFA, proving
+// possession of the authenticator app
+type MFAVerifyRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// MFAChallengeResponse is returned from login in place of tokens when the
+// account has MFA enabled; the client must follow up with MFALoginRequest
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+// MFALoginRequest completes a login that was challenged for MFA, with
+// either a 6-digit TOTP code or a recovery code
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// OIDCLoginRequest signs a user in through a provider the client already
+// authenticated with directly (e.g. a mobile or SPA SDK), rather than the
+// server-driven redirect flow behind /oauth/:provider/login. IDToken is
+// accepted for callers that have one, but AccessToken is what's actually
+// used to call the provider's userinfo endpoint; Provider selects which
+// provider config and endpoint to use.
+type OIDCLoginRequest struct {
+	Provider    string `json:"provider" binding:"required"`
+	IDToken     string `json:"id_token,omitempty"`
+	AccessToken string `json:"access_token" binding:"required"`
+}
+
+// LinkIdentityRequest attaches an external provider identity to the
+// authenticated user's account, the same way OIDCLoginRequest resolves a
+// profile, without signing the caller out of their current session.
+type LinkIdentityRequest struct {
+	Provider    string `json:"provider" binding:"required"`
+	IDToken     string `json:"id_token,omitempty"`
+	AccessToken string `json:"access_token" binding:"required"`
+}
+
+// UnlinkIdentityRequest removes a linked provider identity from the
+// authenticated user's account, identified by the provider in the request
+// path.
+type UnlinkIdentityRequest struct {
+	Provider string `uri:"provider" binding:"required"`
+}
+
+// AcceptTermsRequest records the authenticated user's acceptance of a
+// specific TermsOfService version.
+type AcceptTermsRequest struct {
+	Version string `json:"version" binding:"required"`
+}
+
+// InviteUserRequest represents an admin request to invite a new user
+type InviteUserRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=customer business_owner staff admin"`
+}
+
+// ReauthenticateRequest proves a logged-in user is still present by
+// resubmitting their password, or a TOTP code when MFA is enabled
+type ReauthenticateRequest struct {
+	Password string `json:"password,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+// ReauthenticateResponse carries a short-lived, one-time token proving
+// step-up authentication just occurred. Callers send it back in the
+// X-Reauth-Token header of a subsequent sensitive request
+type ReauthenticateResponse struct {
+	ReauthToken string `json:"reauth_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// AcceptInvitationRequest represents a new user accepting an invitation by
+// verifying their email and setting their password in one step
+type AcceptInvitationRequest struct {
+	Token           string `json:"token" binding:"required"`
+	FirstName       string `json:"first_name" binding:"required"`
+	LastName        string `json:"last_name" binding:"required"`
+	Password        string `json:"password" binding:"required,min=8"`
+	ConfirmPassword string `json:"confirm_password" binding:"required,min=8"`
+}
+
+// SessionResponse describes one of the caller's active login sessions, for
+// a session management UI to list, label, and selectively revoke
+type SessionResponse struct {
+	ID        uint      `json:"id"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	Browser   string    `json:"browser,omitempty"`
+	OS        string    `json:"os,omitempty"`
+	Device    string    `json:"device,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Current   bool      `json:"current"`
+}
+
 // AuthError represents authentication error response
 type AuthError struct {
 	Code    string `json:"code"`
@@ -71,4 +202,11 @@ const (
 	ErrorTokenExpired       = "token_expired"
 	ErrorTokenInvalid       = "token_invalid"
 	ErrorPasswordMismatch   = "password_mismatch"
+	ErrorInvitationInvalid  = "invitation_invalid"
+	ErrorInvitationExpired  = "invitation_expired"
+	ErrorMFARequired        = "mfa_required"
+	ErrorMFAInvalidCode     = "mfa_invalid_code"
+	ErrorReauthRequired     = "reauth_required"
+	ErrorReauthInvalid      = "reauth_invalid"
+	ErrorTermsNotAccepted   = "terms_not_accepted"
 )
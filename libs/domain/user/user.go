@@ -18,6 +18,20 @@ type User struct {
 	IsActive  bool      `gorm:"default:true" json:"is_active"`
 	LastLogin time.Time `json:"last_login,omitempty"`
 	Timezone  string    `gorm:"default:'UTC'" json:"timezone"`
+
+	PasswordChangedAt time.Time `json:"password_changed_at,omitempty"`
+
+	// TokenEpoch invalidates every access token issued before it was last
+	// incremented, even ones still within their 15-minute lifetime. Bumped
+	// by an admin-initiated session revocation. AuthMiddleware caches the
+	// epoch together with the session state, so a replica holding a cached
+	// entry sees the bump only once that entry expires.
+	TokenEpoch int `gorm:"default:0" json:"-"`
+
+	// LinkedIdentities is populated by handlers that want to tell the
+	// client which social/OIDC providers this account can sign in with.
+	// It is not a real column; gorm never loads or persists it.
+	LinkedIdentities []UserIdentity `gorm:"-" json:"linked_identities,omitempty"`
 }
 
 // UserProfile represents additional user details and preferences
@@ -53,7 +67,13 @@ type UserSession struct {
 	IsActive  bool      `gorm:"default:true" json:"is_active"`
 }
 
-// UserVerification represents email/phone verification status
+// UserVerification represents email/phone verification status.
+//
+// Deprecated: EmailToken, PhoneToken, PasswordResetToken, and
+// PasswordResetExpiry are superseded by UserToken, which hashes tokens at
+// rest and supports arbitrary kinds without a schema change per flow. They
+// remain as columns but no handler reads or writes them, so tokens stored
+// in them before the UserToken migration are no longer accepted.
 type UserVerification struct {
 	gorm.Model
 	UserID              string    `gorm:"not null;uniqueIndex" json:"user_id"`
@@ -66,14 +86,253 @@ type UserVerification struct {
 	VerifiedAt          time.Time `json:"verified_at,omitempty"`
 }
 
-// UserActivity represents user activity tracking
+// UserToken is a unified, hashed-at-rest, single-use, time-limited token
+// backing any flow that needs one (email confirmation, password reset,
+// email change, magic-link login, invitations) without growing
+// UserVerification's columns per feature. Only TokenHash is stored; the
+// plaintext is returned once, at creation, for delivery to the user.
+type UserToken struct {
+	gorm.Model
+	UserID     string    `gorm:"not null;index" json:"user_id"`
+	Kind       string    `gorm:"not null;index" json:"kind"`
+	TokenHash  string    `gorm:"not null;uniqueIndex" json:"-"`
+	Payload    string    `gorm:"type:json" json:"payload,omitempty"`
+	ExpiresAt  time.Time `gorm:"not null" json:"expires_at"`
+	ConsumedAt time.Time `json:"consumed_at,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+}
+
+// UserToken kind constants
+const (
+	TokenKindEmailConfirm  = "email_confirm"
+	TokenKindPasswordReset = "password_reset"
+	TokenKindEmailChange   = "email_change"
+	TokenKindMagicLink     = "magic_link"
+	TokenKindInvite        = "invite"
+)
+
+// UserActivity represents a single audit-trail event for a user. Activity
+// is an ActivityKind rather than a bare string so the query API can filter
+// on it reliably; Metadata is one JSON object holding the client IP and
+// user agent plus the fields of the ActivityPayload the event was recorded
+// with, if any.
 type UserActivity struct {
 	gorm.Model
-	UserID    string `gorm:"not null;index" json:"user_id"`
-	Activity  string `gorm:"not null" json:"activity"` // login, logout, booking_created, etc.
-	IPAddress string `json:"ip_address,omitempty"`
-	UserAgent string `json:"user_agent,omitempty"`
-	Metadata  string `gorm:"type:json" json:"metadata,omitempty"` // Additional activity data
+	UserID    string       `gorm:"not null;index" json:"user_id"`
+	Activity  ActivityKind `gorm:"not null;index" json:"activity"`
+	IPAddress string       `json:"ip_address,omitempty"`
+	UserAgent string       `json:"user_agent,omitempty"`
+	Metadata  string       `gorm:"type:json" json:"metadata,omitempty"` // Additional activity data
+}
+
+// ActivityKind identifies the kind of event a UserActivity row records.
+// Not every event the service logs has a constant here: some (e.g. OAuth
+// login by provider) encode provider-specific detail directly in the kind
+// string instead.
+type ActivityKind string
+
+const (
+	ActivityLoginSuccess           ActivityKind = "login_success"
+	ActivityLoginFailure           ActivityKind = "login_failure"
+	ActivityLogoutAll              ActivityKind = "logout_all"
+	ActivityPasswordChanged        ActivityKind = "password_changed"
+	ActivityPasswordResetRequested ActivityKind = "password_reset_requested"
+	ActivityEmailChanged           ActivityKind = "email_changed"
+	ActivityMFAEnrolled            ActivityKind = "mfa_enrolled"
+	ActivityMFAChallengeFailed     ActivityKind = "mfa_challenge_failed"
+	ActivitySessionRevoked         ActivityKind = "session_revoked"
+	ActivityRoleChanged            ActivityKind = "role_changed"
+	ActivityBookingCreated         ActivityKind = "booking_created"
+	ActivityBookingCancelled       ActivityKind = "booking_cancelled"
+	ActivityProfileUpdated         ActivityKind = "profile_updated"
+)
+
+// ActivityPayload is structured detail attached to a UserActivity,
+// marshaled into its Metadata column. Kinds that need no detail beyond
+// the timestamp, IP, and user agent already on UserActivity are recorded
+// with no payload at all.
+type ActivityPayload interface {
+	Kind() ActivityKind
+}
+
+// LoginFailurePayload records why a login attempt failed and how many
+// failures have been recorded for the account within the last hour, this
+// one included, for anomaly detection to act on.
+type LoginFailurePayload struct {
+	Reason       string `json:"reason"`
+	AttemptCount int    `json:"attempt_count"`
+}
+
+// Kind implements ActivityPayload.
+func (LoginFailurePayload) Kind() ActivityKind { return ActivityLoginFailure }
+
+// MFAChallengeFailedPayload records why an MFA challenge at login was
+// rejected.
+type MFAChallengeFailedPayload struct {
+	Reason string `json:"reason"`
+}
+
+// Kind implements ActivityPayload.
+func (MFAChallengeFailedPayload) Kind() ActivityKind { return ActivityMFAChallengeFailed }
+
+// SessionRevokedPayload records which session was revoked and whether the
+// account owner did it themselves or an admin did.
+type SessionRevokedPayload struct {
+	SessionID uint `json:"session_id"`
+	ByAdmin   bool `json:"by_admin"`
+}
+
+// Kind implements ActivityPayload.
+func (SessionRevokedPayload) Kind() ActivityKind { return ActivitySessionRevoked }
+
+// UserInvitation represents an admin-issued invitation that a new user
+// accepts by verifying their email and setting their initial password in
+// one step
+type UserInvitation struct {
+	gorm.Model
+	Email       string    `gorm:"not null;index" json:"email"`
+	Role        string    `gorm:"not null" json:"role"`
+	InvitedByID string    `gorm:"not null" json:"invited_by_id"`
+	Token       string    `gorm:"not null;uniqueIndex" json:"token"`
+	ExpiresAt   time.Time `gorm:"not null" json:"expires_at"`
+	AcceptedAt  time.Time `json:"accepted_at,omitempty"`
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+}
+
+// UserMFA represents a user's multi-factor authentication enrollment.
+// Method is currently always "totp" in practice; SMS/email are reserved for
+// a future second factor that doesn't need an authenticator app. Secret is
+// encrypted at rest via EncryptedField, since it's a live credential rather
+// than merely private data. LastUsedAt records the TOTP step a code was
+// last accepted for, so the same 30-second code can't be replayed.
+type UserMFA struct {
+	gorm.Model
+	UserID     string         `gorm:"not null;uniqueIndex" json:"user_id"`
+	Method     string         `gorm:"not null;default:'totp'" json:"method"`
+	Secret     EncryptedField `gorm:"not null" json:"-"`
+	Enabled    bool           `gorm:"default:false" json:"enabled"`
+	EnabledAt  time.Time      `json:"enabled_at,omitempty"`
+	LastUsedAt time.Time      `json:"-"`
+}
+
+// UserRecoveryCode represents a single-use MFA recovery code. Only the hash
+// is stored; the plaintext code is shown to the user once, at enrollment.
+type UserRecoveryCode struct {
+	gorm.Model
+	UserID   string    `gorm:"not null;index" json:"user_id"`
+	CodeHash string    `gorm:"not null" json:"-"`
+	UsedAt   time.Time `json:"used_at,omitempty"`
+}
+
+// UserIdentity links a User to an identity at an external OAuth/OIDC
+// provider (Google, GitHub, Discord, ...), enabling social login against an
+// existing account. ProviderUserID is the provider's subject identifier
+// (the OIDC spec calls this "sub"); it predates this package's OIDC support
+// and is kept under its original name rather than renamed out from under
+// the working OAuth callback flow. AccessToken and RefreshToken are
+// encrypted at rest via EncryptedField: they're live credentials to the
+// user's account at the provider, not merely private data.
+type UserIdentity struct {
+	gorm.Model
+	UserID         string         `gorm:"not null;index" json:"user_id"`
+	Provider       string         `gorm:"not null;uniqueIndex:idx_provider_identity" json:"provider"`
+	ProviderUserID string         `gorm:"not null;uniqueIndex:idx_provider_identity" json:"provider_user_id"`
+	Email          string         `json:"email,omitempty"`
+	RawClaims      string         `gorm:"type:json" json:"raw_claims,omitempty"`
+	AccessToken    EncryptedField `json:"-"`
+	RefreshToken   EncryptedField `json:"-"`
+	ExpiresAt      time.Time      `json:"expires_at,omitempty"`
+}
+
+// UserInfoFields is a provider userinfo/ID-token claim set decoded into a
+// generic map, since providers disagree on which claims they send and on
+// the concrete type behind them (a numeric "id" vs. a string "sub", a
+// boolean vs. a string "email_verified", and so on). The accessors below
+// normalize those differences instead of every caller re-deriving them.
+type UserInfoFields map[string]any
+
+// GetString returns the string value of key, or "" if it is absent or not
+// a string.
+func (f UserInfoFields) GetString(key string) string {
+	v, ok := f[key].(string)
+	if !ok {
+		return ""
+	}
+	return v
+}
+
+// GetStringFromKeysOrEmpty returns the first non-empty string value found
+// across keys, checked in order, or "" if none match. Useful when
+// providers disagree on a claim's name (e.g. "picture" vs. "avatar_url").
+func (f UserInfoFields) GetStringFromKeysOrEmpty(keys ...string) string {
+	for _, key := range keys {
+		if v := f.GetString(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// GetBoolean returns the boolean value of key. Some providers send
+// "email_verified" as a literal bool, others as the string "true"/"false",
+// so both are accepted.
+func (f UserInfoFields) GetBoolean(key string) bool {
+	switch v := f[key].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+// GetTime returns the value of key parsed as a Unix timestamp (the common
+// encoding for claims like "iat" and "exp"), or the zero Time if key is
+// absent or not numeric.
+func (f UserInfoFields) GetTime(key string) time.Time {
+	switch v := f[key].(type) {
+	case float64:
+		return time.Unix(int64(v), 0)
+	case int64:
+		return time.Unix(v, 0)
+	default:
+		return time.Time{}
+	}
+}
+
+// TermsOfService is a single versioned, published revision of the
+// terms users must accept. Only one version is active at a time; a new
+// version replaces the active flag on the previous one rather than
+// deleting it, so past acceptances keep a meaningful row to point at.
+type TermsOfService struct {
+	gorm.Model
+	Version     string    `gorm:"not null;uniqueIndex" json:"version"`
+	Text        string    `gorm:"type:text;not null" json:"text"`
+	Locale      string    `gorm:"default:'en'" json:"locale"`
+	EffectiveAt time.Time `gorm:"not null" json:"effective_at"`
+	IsActive    bool      `gorm:"default:false" json:"is_active"`
+}
+
+// UserTermsAcceptance records that a user accepted a specific
+// TermsOfService version, with the audit metadata legal review expects
+// alongside the acceptance itself.
+type UserTermsAcceptance struct {
+	gorm.Model
+	UserID     string    `gorm:"not null;uniqueIndex:idx_user_terms" json:"user_id"`
+	TermsID    uint      `gorm:"not null;uniqueIndex:idx_user_terms" json:"terms_id"`
+	AcceptedAt time.Time `gorm:"not null" json:"accepted_at"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// UsedReauthToken records the jti of a redeemed reauth token so it cannot
+// be replayed for the remainder of its validity
+type UsedReauthToken struct {
+	gorm.Model
+	UserID    string    `gorm:"not null;index" json:"user_id"`
+	JTI       string    `gorm:"not null;uniqueIndex" json:"jti"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
 }
 
 // Role constants for consistent role usage across services
@@ -84,6 +343,25 @@ const (
 	RoleAdmin         = "admin"
 )
 
+// MFA method constants. Only MFAMethodTOTP is implemented today;
+// MFAMethodSMS and MFAMethodEmail are reserved for a future second factor.
+const (
+	MFAMethodTOTP  = "totp"
+	MFAMethodSMS   = "sms"
+	MFAMethodEmail = "email"
+)
+
+// OAuth/OIDC identity provider constants. A generic OIDC issuer that isn't
+// one of the named providers is identified as "oidc:<issuer>" instead of
+// adding a constant per issuer.
+const (
+	ProviderGoogle    = "google"
+	ProviderGitHub    = "github"
+	ProviderDiscord   = "discord"
+	ProviderMicrosoft = "microsoft"
+	ProviderApple     = "apple"
+)
+
 // User status constants
 const (
 	StatusActive    = "active"
@@ -114,12 +392,72 @@ func (us *UserSession) IsSessionValid() bool {
 	return us.IsActive && time.Now().Before(us.ExpiresAt)
 }
 
+// IsPending checks if an invitation is still active and not yet expired
+func (ui *UserInvitation) IsPending() bool {
+	return ui.IsActive && time.Now().Before(ui.ExpiresAt)
+}
+
 // TableName overrides the table name for UserVerification
 func (UserVerification) TableName() string {
 	return "user_verifications"
 }
 
+// IsExpired checks whether a token has passed its ExpiresAt
+func (ut *UserToken) IsExpired() bool {
+	return time.Now().After(ut.ExpiresAt)
+}
+
+// IsConsumed checks whether a token has already been redeemed
+func (ut *UserToken) IsConsumed() bool {
+	return !ut.ConsumedAt.IsZero()
+}
+
+// TableName overrides the table name for UserToken
+func (UserToken) TableName() string {
+	return "user_tokens"
+}
+
+// IsUnused checks if a recovery code has not yet been redeemed
+func (rc *UserRecoveryCode) IsUnused() bool {
+	return rc.UsedAt.IsZero()
+}
+
+// TableName overrides the table name for UserInvitation
+func (UserInvitation) TableName() string {
+	return "user_invitations"
+}
+
+// TableName overrides the table name for UserMFA
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// TableName overrides the table name for UserRecoveryCode
+func (UserRecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
+
+// TableName overrides the table name for UserIdentity
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
 // TableName overrides the table name for UserActivity
 func (UserActivity) TableName() string {
 	return "user_activities"
 }
+
+// TableName overrides the table name for UsedReauthToken
+func (UsedReauthToken) TableName() string {
+	return "used_reauth_tokens"
+}
+
+// TableName overrides the table name for TermsOfService
+func (TermsOfService) TableName() string {
+	return "terms_of_service"
+}
+
+// TableName overrides the table name for UserTermsAcceptance
+func (UserTermsAcceptance) TableName() string {
+	return "user_terms_acceptances"
+}
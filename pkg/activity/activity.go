@@ -0,0 +1,29 @@
+// Package activity provides a pluggable fan-out sink for mirroring user
+// activity events somewhere other than the primary Postgres audit trail
+// (a message bus, a ring buffer for tests, ...), without the code that
+// records an activity needing to know what else is listening.
+package activity
+
+import "github.com/shahid-io/studious-pancake/libs/domain/user"
+
+// Sink receives a copy of every recorded UserActivity. Implementations
+// must be safe for concurrent use.
+type Sink interface {
+	Record(a user.UserActivity) error
+}
+
+// FanOut mirrors every Record call to each of its sinks. A nil or empty
+// FanOut is a valid no-op sink.
+type FanOut []Sink
+
+// Record implements Sink, returning the first error encountered after
+// still calling every sink.
+func (f FanOut) Record(a user.UserActivity) error {
+	var firstErr error
+	for _, sink := range f {
+		if err := sink.Record(a); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
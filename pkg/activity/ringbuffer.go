@@ -0,0 +1,54 @@
+package activity
+
+import (
+	"sync"
+
+	"github.com/shahid-io/studious-pancake/libs/domain/user"
+)
+
+// RingBuffer is an in-memory Sink that keeps only the most recently
+// recorded activities, for tests and local development where standing up
+// a real message bus isn't worth it.
+type RingBuffer struct {
+	mu       sync.Mutex
+	entries  []user.UserActivity
+	capacity int
+	next     int
+	filled   bool
+}
+
+// NewRingBuffer creates a RingBuffer holding at most capacity entries.
+func NewRingBuffer(capacity int) *RingBuffer {
+	return &RingBuffer{entries: make([]user.UserActivity, capacity), capacity: capacity}
+}
+
+// Record implements Sink, overwriting the oldest entry once the buffer is
+// full.
+func (r *RingBuffer) Record(a user.UserActivity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = a
+	r.next = (r.next + 1) % r.capacity
+	if r.next == 0 {
+		r.filled = true
+	}
+	return nil
+}
+
+// Entries returns a copy of the buffered activities, oldest first.
+func (r *RingBuffer) Entries() []user.UserActivity {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.filled {
+		out := make([]user.UserActivity, r.next)
+		copy(out, r.entries[:r.next])
+		return out
+	}
+
+	out := make([]user.UserActivity, r.capacity)
+	n := copy(out, r.entries[r.next:])
+	copy(out[n:], r.entries[:r.next])
+	return out
+}
@@ -1,40 +1,227 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
-
-	"github.com/joho/godotenv"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Config holds the auth-service runtime configuration. Fields declare their
+// environment variable, default, and required status via the `env` struct
+// tag so adding a new setting never requires touching Load.
 type Config struct {
-	DatabaseURL string
-	AppPort     string
-	JWTSecret   string
-	RedisURL    string
-	Environment string
+	DatabaseURL string `env:"DATABASE_URL,required" secret:"true"`
+	AppPort     string `env:"AUTH_SERVICE_PORT,default=8080" immutable:"true"`
+	JWTSecret   string `env:"JWT_SECRET,required" secret:"true"`
+
+	JWTAlgorithm  string        `env:"JWT_ALGORITHM,default=RS256"`
+	JWTKeyOverlap time.Duration `env:"JWT_KEY_OVERLAP,default=24h"`
+	RedisURL      string        `env:"REDIS_URL,default=localhost:6379"`
+	Environment   string        `env:"ENVIRONMENT,default=development"`
+
+	RateLimitBackend  string `env:"RATE_LIMIT_BACKEND,default=memory"`
+	RateLimitStrategy string `env:"RATE_LIMIT_STRATEGY,default=sliding-window"`
+
+	// RPCAuthSecret is a hex-encoded 256-bit shared secret used to sign and
+	// verify the internal service-to-service JWTs accepted by
+	// RPCAuthMiddleware. Internal RPC endpoints are unregistered when unset.
+	RPCAuthSecret string `env:"RPC_AUTH_SECRET" secret:"true"`
+
+	// EncryptionKey is a hex-encoded 256-bit AES key used to encrypt
+	// user.EncryptedField columns (MFA secrets, OAuth access/refresh
+	// tokens) at rest.
+	EncryptionKey string `env:"ENCRYPTION_KEY,required" secret:"true"`
+
+	CORSAllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS,default=*"`
+	CORSAllowedMethods   []string      `env:"CORS_ALLOWED_METHODS"`
+	CORSAllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS"`
+	CORSExposedHeaders   []string      `env:"CORS_EXPOSED_HEADERS"`
+	CORSAllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS,default=false"`
+	CORSMaxAge           time.Duration `env:"CORS_MAX_AGE,default=12h"`
+
+	AppName      string `env:"APP_NAME,default=Studious Pancake"`
+	SMTPHost     string `env:"SMTP_HOST"`
+	SMTPPort     string `env:"SMTP_PORT,default=587"`
+	SMTPUsername string `env:"SMTP_USERNAME"`
+	SMTPPassword string `env:"SMTP_PASSWORD" secret:"true"`
+	SMTPFrom     string `env:"SMTP_FROM,default=no-reply@example.com"`
+	SMTPTLSMode  string `env:"SMTP_TLS_MODE,default=starttls"`
+
+	OAuthRedirectBaseURL string `env:"OAUTH_REDIRECT_BASE_URL,default=http://localhost:8080"`
+	GoogleClientID       string `env:"GOOGLE_CLIENT_ID"`
+	GoogleClientSecret   string `env:"GOOGLE_CLIENT_SECRET" secret:"true"`
+	GitHubClientID       string `env:"GITHUB_CLIENT_ID"`
+	GitHubClientSecret   string `env:"GITHUB_CLIENT_SECRET" secret:"true"`
+	DiscordClientID      string `env:"DISCORD_CLIENT_ID"`
+	DiscordClientSecret  string `env:"DISCORD_CLIENT_SECRET" secret:"true"`
+
+	sources     []SourceInfo
+	secretLease time.Duration
 }
 
-func Load() *Config {
-	// Load .env file if it exists
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using default environment values")
+// String implements fmt.Stringer, redacting fields tagged `secret:"true"` so
+// a Config never leaks resolved secrets into logs.
+func (c Config) String() string {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+
+	parts := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := fmt.Sprintf("%v", v.Field(i).Interface())
+		if field.Tag.Get("secret") == "true" {
+			value = "***REDACTED***"
+		}
+		parts = append(parts, fmt.Sprintf("%s:%s", field.Name, value))
 	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// Load reads configuration for the profile named by the ENVIRONMENT
+// variable (defaulting to "development"). See LoadWithProfile for the full
+// source chain.
+func Load() (*Config, error) {
+	return LoadWithProfile(currentProfile())
+}
 
-	return &Config{
-		DatabaseURL: getEnv("DATABASE_URL", "host=localhost user=postgres password=secret dbname=mydb port=5432 sslmode=disable"),
-		AppPort:     getEnv("AUTH_SERVICE_PORT", "8080"),
-		JWTSecret:   getEnv("JWT_SECRET", "Hello_world"),
-		RedisURL:    getEnv("REDIS_URL", "localhost:6379"),
-		Environment: getEnv("ENVIRONMENT", "development"),
+// currentProfile returns the ENVIRONMENT value from the process, without
+// going through the layered loader, since it decides which profile file to
+// load in the first place.
+func currentProfile() string {
+	if env := os.Getenv("ENVIRONMENT"); env != "" {
+		return env
 	}
+	return "development"
 }
 
-// getEnv returns the value of the environment variable or fallback
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// decodeEnv walks v (and any nested structs) assigning values per the `env`
+// tag on each field by consulting layers in priority order (later layers
+// override earlier ones), and returns the names of any required variables
+// left unset plus a SourceInfo record for every field that was set.
+func decodeEnv(v reflect.Value, layers []valueLayer) ([]string, []SourceInfo) {
+	var missing []string
+	var sources []SourceInfo
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldValue := v.Field(i)
+
+		if fieldValue.Kind() == reflect.Struct {
+			m, s := decodeEnv(fieldValue, layers)
+			missing = append(missing, m...)
+			sources = append(sources, s...)
+			continue
+		}
+
+		tag := field.Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+
+		name, required, defaultValue := parseEnvTag(tag)
+
+		raw := defaultValue
+		source := "default"
+		found := defaultValue != ""
+
+		for _, layer := range layers {
+			if val, ok := layer.lookup(name); ok {
+				raw, source, found = val, layer.name, true
+			}
+		}
+
+		if !found {
+			if required {
+				missing = append(missing, name)
+			}
+			continue
+		}
+
+		if err := setField(fieldValue, raw); err != nil {
+			log.Printf("config: invalid value for %s: %v", name, err)
+			continue
+		}
+
+		sources = append(sources, SourceInfo{Variable: name, Value: raw, Source: source})
+	}
+
+	return missing, sources
+}
+
+// parseEnvTag splits an `env:"NAME,required"` or `env:"NAME,default=value"`
+// tag into its component parts. Only NAME is comma-delimited from what
+// follows: everything after "default=" is taken verbatim as the default
+// value, commas and all, so a list-valued default like
+// "default=GET,POST,PUT" isn't truncated to its first element.
+func parseEnvTag(tag string) (name string, required bool, defaultValue string) {
+	name, rest, hasRest := strings.Cut(tag, ",")
+	if !hasRest {
+		return name, false, ""
+	}
+
+	switch {
+	case rest == "required":
+		required = true
+	case strings.HasPrefix(rest, "default="):
+		defaultValue = strings.TrimPrefix(rest, "default=")
+	}
+
+	return name, required, defaultValue
+}
+
+func setField(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		if raw == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if raw == "" {
+			return nil
+		}
+		if field.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice element type %s", field.Type().Elem())
+		}
+		if raw == "" {
+			return nil
+		}
+		var values []string
+		for _, part := range strings.Split(raw, ",") {
+			values = append(values, strings.TrimSpace(part))
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Kind())
 	}
-	return value
+
+	return nil
 }
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/joho/godotenv"
+	toml "github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// searchPaths is the set of directories, in order, that profile and .env
+// files are looked up in. Mirrors the repo's existing
+// godotenv.Load(".env", "../.env") convention of also checking the parent
+// directory, which lets services be run either from their own directory or
+// from the repo root.
+var searchPaths = []string{".", ".."}
+
+// SourceInfo describes where a single configuration value ultimately came
+// from, for operators debugging a running service via Config.Sources().
+type SourceInfo struct {
+	Variable string
+	Value    string
+	Source   string
+}
+
+// valueLayer is one link in the configuration source chain. Layers are
+// consulted in order and later layers override earlier ones.
+type valueLayer struct {
+	name   string
+	lookup func(name string) (string, bool)
+}
+
+// LoadWithProfile loads configuration for the given profile through the
+// layered source chain: built-in defaults -> config.<profile>.(yaml|toml) ->
+// .env -> process environment -> command-line flags. Each source overrides
+// the ones before it. All missing required variables are collected into a
+// single aggregated error.
+func LoadWithProfile(profile string) (*Config, error) {
+	layers := []valueLayer{
+		{name: profileSourceLabel(profile), lookup: mapLookup(readProfileFile(profile))},
+		{name: ".env", lookup: mapLookup(readDotEnv())},
+		{name: "environment", lookup: os.LookupEnv},
+		{name: "flag", lookup: mapLookup(readFlags())},
+	}
+
+	cfg := &Config{}
+	missing, sources := decodeEnv(reflect.ValueOf(cfg).Elem(), layers)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("config: missing required environment variables: %s", strings.Join(missing, ", "))
+	}
+
+	lease, err := resolveSecrets(reflect.ValueOf(cfg).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.sources = sources
+	cfg.secretLease = lease
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Sources returns, for every configuration value that was set, which source
+// supplied it. Useful for logging at startup so operators can tell a
+// profile file override from a stray environment variable.
+func (c *Config) Sources() []SourceInfo {
+	return c.sources
+}
+
+// mapLookup adapts a plain map to the valueLayer lookup signature.
+func mapLookup(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+}
+
+// findInSearchPaths returns the first existing file named filename under
+// searchPaths, or "" if none is found.
+func findInSearchPaths(filename string) string {
+	for _, dir := range searchPaths {
+		path := filepath.Join(dir, filename)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// profileSourceLabel returns the path of the profile file that would be
+// loaded for profile, for use as a SourceInfo label even before it's parsed.
+func profileSourceLabel(profile string) string {
+	if path := findInSearchPaths(fmt.Sprintf("config.%s.yaml", profile)); path != "" {
+		return path
+	}
+	if path := findInSearchPaths(fmt.Sprintf("config.%s.toml", profile)); path != "" {
+		return path
+	}
+	return fmt.Sprintf("config.%s.yaml", profile)
+}
+
+// readProfileFile loads config.<profile>.yaml (or .toml) from the search
+// paths into a flat string map keyed by the same names used in `env` tags.
+// A missing file is not an error; it simply contributes nothing.
+func readProfileFile(profile string) map[string]string {
+	if path := findInSearchPaths(fmt.Sprintf("config.%s.yaml", profile)); path != "" {
+		return parseValuesFile(path, yaml.Unmarshal)
+	}
+	if path := findInSearchPaths(fmt.Sprintf("config.%s.toml", profile)); path != "" {
+		return parseValuesFile(path, toml.Unmarshal)
+	}
+	return nil
+}
+
+func parseValuesFile(path string, unmarshal func([]byte, interface{}) error) map[string]string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("config: failed to read %s: %v", path, err)
+		return nil
+	}
+
+	var raw map[string]interface{}
+	if err := unmarshal(data, &raw); err != nil {
+		log.Printf("config: failed to parse %s: %v", path, err)
+		return nil
+	}
+
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		values[strings.ToUpper(k)] = fmt.Sprintf("%v", v)
+	}
+	return values
+}
+
+// readDotEnv loads .env from the search paths without mutating the process
+// environment, so it can be reported as its own layer distinct from
+// "environment" in Sources().
+func readDotEnv() map[string]string {
+	var paths []string
+	for _, dir := range searchPaths {
+		path := filepath.Join(dir, ".env")
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	values, err := godotenv.Read(paths...)
+	if err != nil {
+		log.Printf("config: failed to read .env: %v", err)
+		return nil
+	}
+	return values
+}
+
+// readFlags registers one flag per `env` tag on Config (the dashed,
+// lowercased form of the variable name, e.g. DATABASE_URL -> -database-url)
+// and parses os.Args. A parse error, such as an unrecognized flag, is not
+// fatal, so this doesn't interfere with flags owned by other parts of the
+// service; parsing stops at the first unrecognized flag, so config flags
+// after it are not read.
+func readFlags() map[string]string {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	names := make(map[string]bool)
+	collectEnvNames(reflect.TypeOf(Config{}), names)
+
+	pointers := make(map[string]*string, len(names))
+	for name := range names {
+		flagName := strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+		pointers[name] = fs.String(flagName, "", fmt.Sprintf("override for %s", name))
+	}
+
+	_ = fs.Parse(os.Args[1:])
+
+	values := make(map[string]string)
+	for name, ptr := range pointers {
+		if *ptr != "" {
+			values[name] = *ptr
+		}
+	}
+	return values
+}
+
+func collectEnvNames(t reflect.Type, out map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			collectEnvNames(field.Type, out)
+			continue
+		}
+		tag := field.Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+		name, _, _ := parseEnvTag(tag)
+		out[name] = true
+	}
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const insecureDefaultJWTSecret = "Hello_world"
+
+// Validate goes beyond presence checks: it parses DatabaseURL as a DSN,
+// confirms AppPort and RedisURL are usable addresses, rejects an
+// insecure-default or too-short JWTSecret, constrains Environment,
+// RateLimitBackend, RateLimitStrategy, SMTPTLSMode, and JWTAlgorithm to a
+// known set, rejects a credentialed CORS policy that also wildcards
+// origins (browsers refuse that combination outright), and requires
+// EncryptionKey (always) and RPCAuthSecret (when set) to each decode to 32
+// bytes of hex. All failures are aggregated via errors.Join rather than
+// stopping at the first one, so a misconfigured deploy reports everything
+// wrong with it in one pass.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if _, err := databaseHostPort(c.DatabaseURL); err != nil {
+		errs = append(errs, fmt.Errorf("DatabaseURL: %w", err))
+	}
+
+	if _, err := parsePort(c.AppPort); err != nil {
+		errs = append(errs, fmt.Errorf("AppPort: %w", err))
+	}
+
+	if len(c.JWTSecret) < 32 {
+		errs = append(errs, fmt.Errorf("JWTSecret: must be at least 32 bytes, got %d", len(c.JWTSecret)))
+	}
+	if c.Environment == "production" && c.JWTSecret == insecureDefaultJWTSecret {
+		errs = append(errs, fmt.Errorf("JWTSecret: insecure default value must not be used in production"))
+	}
+
+	if _, _, err := net.SplitHostPort(c.RedisURL); err != nil {
+		errs = append(errs, fmt.Errorf("RedisURL: must be host:port: %w", err))
+	}
+
+	switch c.Environment {
+	case "development", "staging", "production":
+	default:
+		errs = append(errs, fmt.Errorf("Environment: must be one of development, staging, production, got %q", c.Environment))
+	}
+
+	switch c.RateLimitBackend {
+	case "memory", "redis":
+	default:
+		errs = append(errs, fmt.Errorf("RateLimitBackend: must be one of memory, redis, got %q", c.RateLimitBackend))
+	}
+
+	switch c.RateLimitStrategy {
+	case "fixed-window", "sliding-window", "token-bucket":
+	default:
+		errs = append(errs, fmt.Errorf("RateLimitStrategy: must be one of fixed-window, sliding-window, token-bucket, got %q", c.RateLimitStrategy))
+	}
+
+	switch c.SMTPTLSMode {
+	case "none", "starttls", "tls":
+	default:
+		errs = append(errs, fmt.Errorf("SMTPTLSMode: must be one of none, starttls, tls, got %q", c.SMTPTLSMode))
+	}
+
+	switch c.JWTAlgorithm {
+	case "RS256", "ES256":
+	default:
+		errs = append(errs, fmt.Errorf("JWTAlgorithm: must be one of RS256, ES256, got %q", c.JWTAlgorithm))
+	}
+
+	if c.CORSAllowCredentials {
+		for _, origin := range c.CORSAllowedOrigins {
+			if origin == "*" {
+				errs = append(errs, fmt.Errorf("CORSAllowedOrigins: must not contain \"*\" when CORSAllowCredentials is true"))
+				break
+			}
+		}
+	}
+
+	if c.RPCAuthSecret != "" {
+		if decoded, err := hex.DecodeString(c.RPCAuthSecret); err != nil {
+			errs = append(errs, fmt.Errorf("RPCAuthSecret: must be hex-encoded: %w", err))
+		} else if len(decoded) != 32 {
+			errs = append(errs, fmt.Errorf("RPCAuthSecret: must decode to 32 bytes, got %d", len(decoded)))
+		}
+	}
+
+	if decoded, err := hex.DecodeString(c.EncryptionKey); err != nil {
+		errs = append(errs, fmt.Errorf("EncryptionKey: must be hex-encoded: %w", err))
+	} else if len(decoded) != 32 {
+		errs = append(errs, fmt.Errorf("EncryptionKey: must decode to 32 bytes, got %d", len(decoded)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Healthz dials Postgres and Redis using the addresses in DatabaseURL and
+// RedisURL so a /healthz handler can report configuration-level readiness
+// at boot, before the rest of the service has built its own connection
+// pools.
+func (c *Config) Healthz(ctx context.Context) error {
+	var errs []error
+	var dialer net.Dialer
+
+	if hostPort, err := databaseHostPort(c.DatabaseURL); err != nil {
+		errs = append(errs, fmt.Errorf("postgres: %w", err))
+	} else if conn, err := dialer.DialContext(ctx, "tcp", hostPort); err != nil {
+		errs = append(errs, fmt.Errorf("postgres: %w", err))
+	} else {
+		conn.Close()
+	}
+
+	if conn, err := dialer.DialContext(ctx, "tcp", c.RedisURL); err != nil {
+		errs = append(errs, fmt.Errorf("redis: %w", err))
+	} else {
+		conn.Close()
+	}
+
+	return errors.Join(errs...)
+}
+
+// parsePort validates s as a TCP port number.
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("not a number: %w", err)
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("out of range 1-65535: %d", port)
+	}
+	return port, nil
+}
+
+// databaseHostPort extracts a host:port pair from DatabaseURL, which may be
+// either a postgres:// URL or a libpq keyword/value DSN
+// (e.g. "host=localhost port=5432 dbname=mydb").
+func databaseHostPort(dsn string) (string, error) {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", fmt.Errorf("invalid DSN: %w", err)
+		}
+		if u.Host == "" {
+			return "", fmt.Errorf("invalid DSN: missing host")
+		}
+		if u.Port() == "" {
+			return net.JoinHostPort(u.Hostname(), "5432"), nil
+		}
+		return u.Host, nil
+	}
+
+	fields := map[string]string{}
+	for _, kv := range strings.Fields(dsn) {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		fields[k] = v
+	}
+
+	host, ok := fields["host"]
+	if !ok || host == "" {
+		return "", fmt.Errorf("invalid DSN: missing host")
+	}
+	port := fields["port"]
+	if port == "" {
+		port = "5432"
+	}
+	if _, ok := fields["dbname"]; !ok {
+		return "", fmt.Errorf("invalid DSN: missing dbname")
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
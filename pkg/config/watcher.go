@@ -0,0 +1,248 @@
+package config
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// current holds the most recently loaded Config for package-level access via
+// Current. It's only populated once a Watcher has loaded at least once.
+var current atomic.Pointer[Config]
+
+// Current returns the most recently loaded Config, or nil if no Watcher has
+// loaded one yet.
+func Current() *Config {
+	return current.Load()
+}
+
+// Watcher keeps a Config current by re-reading its sources on SIGHUP and on
+// changes to the watched profile/.env files, swapping Current() atomically
+// and notifying subscribers registered via OnChange.
+type Watcher struct {
+	profile string
+
+	mu        sync.Mutex
+	callbacks []func(old, new *Config)
+
+	fsWatcher *fsnotify.Watcher
+	sigCh     chan os.Signal
+	stopCh    chan struct{}
+}
+
+// NewWatcher performs an initial load for profile, publishes it via
+// Current, and starts watching for reload triggers. Callers must call
+// Close when done.
+func NewWatcher(profile string) (*Watcher, error) {
+	cfg, err := LoadWithProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	current.Store(cfg)
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range watchedFiles(profile) {
+		if err := fsWatcher.Add(path); err != nil {
+			log.Printf("config: not watching %s: %v", path, err)
+		}
+	}
+
+	w := &Watcher{
+		profile:   profile,
+		fsWatcher: fsWatcher,
+		sigCh:     make(chan os.Signal, 1),
+		stopCh:    make(chan struct{}),
+	}
+	signal.Notify(w.sigCh, syscall.SIGHUP)
+
+	go w.run()
+	return w, nil
+}
+
+// OnChange registers fn to be called, with the previous and newly loaded
+// Config, after every successful reload.
+func (w *Watcher) OnChange(fn func(old, new *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callbacks = append(w.callbacks, fn)
+}
+
+// Close stops watching for reload triggers. It does not clear Current().
+func (w *Watcher) Close() error {
+	close(w.stopCh)
+	signal.Stop(w.sigCh)
+	return w.fsWatcher.Close()
+}
+
+func (w *Watcher) run() {
+	renewTimer := time.NewTimer(leaseRenewalDelay(current.Load()))
+	defer renewTimer.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-w.sigCh:
+			log.Println("config: SIGHUP received, reloading")
+			w.reload()
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				log.Printf("config: %s changed, reloading", event.Name)
+				w.reload()
+			}
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("config: watcher error: %v", err)
+		case <-renewTimer.C:
+			log.Println("config: secret lease expiring, renewing")
+			w.reload()
+		}
+		renewTimer.Reset(leaseRenewalDelay(current.Load()))
+	}
+}
+
+// leaseRenewalDelay returns how long to wait before renewing cfg's
+// lease-based secrets, renewing a little early to avoid racing expiry. If
+// cfg has no lease-based secrets, it returns a long delay so the timer is
+// effectively inert.
+func leaseRenewalDelay(cfg *Config) time.Duration {
+	if cfg == nil || cfg.secretLease <= 0 {
+		return 24 * time.Hour
+	}
+	if cfg.secretLease <= time.Minute {
+		return cfg.secretLease
+	}
+	return cfg.secretLease - time.Minute
+}
+
+// reload re-reads configuration, rejects changes to any field marked
+// `immutable:"true"`, logs a diff of what changed, and publishes the result
+// via Current and OnChange subscribers.
+func (w *Watcher) reload() {
+	old := current.Load()
+
+	next, err := LoadWithProfile(w.profile)
+	if err != nil {
+		log.Printf("config: reload failed, keeping previous config: %v", err)
+		return
+	}
+
+	diff := rejectImmutableChanges(old, next)
+	if len(diff) == 0 {
+		return
+	}
+	log.Printf("config: reloaded with changes: %s", diff)
+
+	current.Store(next)
+
+	w.mu.Lock()
+	callbacks := append([]func(old, new *Config){}, w.callbacks...)
+	w.mu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(old, next)
+	}
+}
+
+// rejectImmutableChanges compares old and next field by field. Any field
+// tagged `immutable:"true"` whose value changed is reverted on next (in
+// place) and reported as rejected; every other changed field is reported as
+// applied. The returned string is a human-readable summary for logging.
+func rejectImmutableChanges(old, next *Config) string {
+	if old == nil {
+		return "initial load"
+	}
+
+	var applied, rejected []string
+	diffFields(reflect.ValueOf(old).Elem(), reflect.ValueOf(next).Elem(), &applied, &rejected)
+
+	if len(applied) == 0 && len(rejected) == 0 {
+		return ""
+	}
+
+	summary := ""
+	if len(applied) > 0 {
+		summary += "changed=" + joinStrings(applied)
+	}
+	if len(rejected) > 0 {
+		if summary != "" {
+			summary += " "
+		}
+		summary += "rejected(immutable)=" + joinStrings(rejected)
+	}
+	return summary
+}
+
+func diffFields(oldV, nextV reflect.Value, applied, rejected *[]string) {
+	t := oldV.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		oldField := oldV.Field(i)
+		nextField := nextV.Field(i)
+
+		if oldField.Kind() == reflect.Struct {
+			diffFields(oldField, nextField, applied, rejected)
+			continue
+		}
+		if field.Tag.Get("env") == "" || !oldField.CanInterface() {
+			continue
+		}
+		if reflect.DeepEqual(oldField.Interface(), nextField.Interface()) {
+			continue
+		}
+
+		name, _, _ := parseEnvTag(field.Tag.Get("env"))
+		if field.Tag.Get("immutable") == "true" {
+			nextField.Set(oldField)
+			*rejected = append(*rejected, name)
+			continue
+		}
+		*applied = append(*applied, name)
+	}
+}
+
+func joinStrings(values []string) string {
+	out := ""
+	for i, v := range values {
+		if i > 0 {
+			out += ","
+		}
+		out += v
+	}
+	return out
+}
+
+// watchedFiles returns the concrete paths NewWatcher should watch for
+// profile: the resolved profile file (if it exists) and any .env files
+// found in the search paths.
+func watchedFiles(profile string) []string {
+	var files []string
+	if path := findInSearchPaths("config." + profile + ".yaml"); path != "" {
+		files = append(files, path)
+	}
+	if path := findInSearchPaths("config." + profile + ".toml"); path != "" {
+		files = append(files, path)
+	}
+	for _, dir := range searchPaths {
+		path := dir + string(os.PathSeparator) + ".env"
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
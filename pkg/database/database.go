@@ -1,32 +1,151 @@
+// Package database connects to the auth-service's relational store behind
+// a small driver registry, so the concrete SQL driver (postgres, mysql,
+// sqlite, ...) is a runtime choice instead of a compile-time import, and
+// wires up the connection pool, retry, and health-check behavior every
+// deployment of this service needs regardless of which driver it picked.
 package database
 
 import (
 	"fmt"
 	"log"
+	"math/rand"
 	"time"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func Connect(dsn string) *gorm.DB {
+// Config describes how to open and tune a database connection. Driver
+// selects a Dialector registered via RegisterDriver; everything else
+// mirrors the knobs database/sql exposes for pool sizing plus this
+// package's own retry and logging behavior.
+type Config struct {
+	Driver string
+	DSN    string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// RetryMax is the number of connection attempts before Connect gives
+	// up. RetryBackoff is the base delay for the first retry; each
+	// subsequent retry doubles it (capped at 30s) with up to 20% jitter
+	// applied, so many instances starting at once don't all retry in
+	// lockstep.
+	RetryMax     int
+	RetryBackoff time.Duration
+
+	// SlowQueryThreshold is passed to gorm's logger so queries slower than
+	// it are logged as slow when LogLevel is Warn or Info; zero means 200ms.
+	SlowQueryThreshold time.Duration
+	LogLevel           LogLevel
+}
+
+// LogLevel controls how much gorm logs about the queries it runs.
+type LogLevel int
+
+const (
+	LogLevelSilent LogLevel = iota
+	LogLevelError
+	LogLevelWarn
+	LogLevelInfo
+)
+
+const retryBackoffCap = 30 * time.Second
+
+// DriverOpener builds a gorm Dialector for dsn. Registered drivers are
+// looked up by name in Connect.
+type DriverOpener func(dsn string) gorm.Dialector
+
+var drivers = map[string]DriverOpener{}
+
+// RegisterDriver makes opener available under name for Connect to use.
+// Drivers register themselves from an init() in their own file (see
+// postgres.go), so adding mysql or sqlite support is a new file, not a
+// change to Connect.
+func RegisterDriver(name string, opener DriverOpener) {
+	drivers[name] = opener
+}
+
+// Connect opens a database connection per cfg, retrying with exponential
+// backoff and jitter up to cfg.RetryMax times instead of the caller's
+// process exiting on a slow-starting database. It returns an error rather
+// than calling log.Fatal, leaving that decision to the caller.
+func Connect(cfg Config) (*gorm.DB, error) {
+	opener, ok := drivers[cfg.Driver]
+	if !ok {
+		return nil, fmt.Errorf("database: no driver registered for %q", cfg.Driver)
+	}
+
+	maxRetries := cfg.RetryMax
+	if maxRetries <= 0 {
+		maxRetries = 10
+	}
+	backoff := cfg.RetryBackoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
 	var db *gorm.DB
 	var err error
 
-	maxRetries := 10
-	retryInterval := 3 * time.Second
-
-	for i := 1; i <= maxRetries; i++ {
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		db, err = gorm.Open(opener(cfg.DSN), &gorm.Config{
+			Logger: newLogger(cfg.LogLevel, cfg.SlowQueryThreshold),
+		})
 		if err == nil {
-			fmt.Println("Database connected [Posgres]")
-			return db
+			if tuneErr := tunePool(db, cfg); tuneErr != nil {
+				return nil, fmt.Errorf("database: failed to configure connection pool: %w", tuneErr)
+			}
+			log.Printf("database: connected [%s]", cfg.Driver)
+			return db, nil
 		}
 
-		log.Printf("[attempt %d/%d] failed to connect to database: %v", i, maxRetries, err)
-		time.Sleep(retryInterval)
+		log.Printf("database: [attempt %d/%d] failed to connect: %v", attempt, maxRetries, err)
+		if attempt < maxRetries {
+			time.Sleep(backoffWithJitter(backoff, attempt))
+		}
+	}
+
+	return nil, fmt.Errorf("database: failed to connect after %d attempts: %w", maxRetries, err)
+}
+
+// tunePool applies cfg's pool settings to db's underlying *sql.DB.
+func tunePool(db *gorm.DB, cfg Config) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 	}
 
-	log.Fatal("[error] failed to initialize database after multiple attempts:", err)
 	return nil
 }
+
+// backoffWithJitter returns the delay before the given retry attempt:
+// base * 2^(attempt-1), capped at retryBackoffCap, with up to 20% jitter
+// added so concurrently-starting instances don't retry in lockstep.
+func backoffWithJitter(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= retryBackoffCap {
+			delay = retryBackoffCap
+			break
+		}
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay) / 5))
+	return delay + jitter
+}
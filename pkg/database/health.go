@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Ping checks that db is reachable, honoring ctx's deadline.
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Stats returns the connection pool statistics (open, idle, in-use, wait
+// count, ...) for db's underlying *sql.DB.
+func Stats(db *gorm.DB) (sql.DBStats, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
+
+// HealthCheck reports whether db is reachable, in a form suitable for a
+// /healthz handler to call directly.
+func HealthCheck(ctx context.Context, db *gorm.DB) error {
+	if err := Ping(ctx, db); err != nil {
+		return fmt.Errorf("database: health check failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"log"
+	"os"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// newLogger builds gorm's logger configured from level and slowThreshold,
+// so Config's LogLevel/SlowQueryThreshold map directly onto gorm's own
+// logging knobs instead of callers reaching past this package.
+func newLogger(level LogLevel, slowThreshold time.Duration) gormlogger.Interface {
+	if slowThreshold <= 0 {
+		slowThreshold = 200 * time.Millisecond
+	}
+
+	var gormLevel gormlogger.LogLevel
+	switch level {
+	case LogLevelError:
+		gormLevel = gormlogger.Error
+	case LogLevelWarn:
+		gormLevel = gormlogger.Warn
+	case LogLevelInfo:
+		gormLevel = gormlogger.Info
+	default:
+		gormLevel = gormlogger.Silent
+	}
+
+	return gormlogger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), gormlogger.Config{
+		SlowThreshold: slowThreshold,
+		LogLevel:      gormLevel,
+	})
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Metrics is a pluggable sink for connection pool gauges, so this package
+// doesn't hardcode a metrics backend (Prometheus, statsd, ...); callers
+// implement it against whichever one they already use.
+type Metrics interface {
+	SetGauge(name string, value float64)
+}
+
+// StartMetricsReporter polls db's connection pool on interval and reports
+// open/idle/in-use/wait-count gauges through m, until ctx is canceled.
+// Call it as "go StartMetricsReporter(...)"; it blocks until ctx is done.
+func StartMetricsReporter(ctx context.Context, db *gorm.DB, m Metrics, interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, err := Stats(db)
+			if err != nil {
+				continue
+			}
+			m.SetGauge("db_connections_open", float64(stats.OpenConnections))
+			m.SetGauge("db_connections_idle", float64(stats.Idle))
+			m.SetGauge("db_connections_in_use", float64(stats.InUse))
+			m.SetGauge("db_connections_wait_count", float64(stats.WaitCount))
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// AutoMigrate runs gorm's AutoMigrate for each of models, one at a time
+// inside a single transaction, logging each step so a failed migration
+// points at the specific model that caused it instead of the batch as a
+// whole. The whole batch is rolled back on the first failure.
+func AutoMigrate(db *gorm.DB, models ...interface{}) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range models {
+			log.Printf("database: migrating %T", model)
+			if err := tx.AutoMigrate(model); err != nil {
+				return fmt.Errorf("database: migrating %T: %w", model, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,12 @@
+package database
+
+import (
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func init() {
+	RegisterDriver("postgres", func(dsn string) gorm.Dialector {
+		return postgres.Open(dsn)
+	})
+}
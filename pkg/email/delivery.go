@@ -0,0 +1,25 @@
+package email
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Delivery records one attempted send for auditing and retry. MessageID is
+// generated locally at send time as a correlation ID, since net/smtp has no
+// provider message ID to hand back.
+type Delivery struct {
+	gorm.Model
+	To        string    `gorm:"not null;index" json:"to"`
+	Template  string    `gorm:"not null" json:"template"`
+	MessageID string    `gorm:"not null;uniqueIndex" json:"message_id"`
+	Success   bool      `gorm:"default:false" json:"success"`
+	Error     string    `json:"error,omitempty"`
+	SentAt    time.Time `json:"sent_at,omitempty"`
+}
+
+// TableName overrides the table name for Delivery.
+func (Delivery) TableName() string {
+	return "email_deliveries"
+}
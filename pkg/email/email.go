@@ -0,0 +1,25 @@
+// Package email renders and delivers the service's transactional emails
+// (verification, password reset, invitation) through a pluggable Mailer.
+package email
+
+import (
+	"context"
+	"time"
+
+	"github.com/shahid-io/studious-pancake/libs/domain/user"
+)
+
+// Mailer sends a single transactional email. Implementations must be safe
+// for concurrent use, since callers dispatch through a worker pool.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, htmlBody, textBody string) error
+}
+
+// TemplateData carries the values every transactional email template can
+// reference.
+type TemplateData struct {
+	AppName   string
+	ActionURL string
+	ExpiresAt time.Time
+	User      user.User
+}
@@ -0,0 +1,16 @@
+package email
+
+import (
+	"context"
+	"log"
+)
+
+// NoopMailer logs the email instead of sending it, so the registration,
+// password-reset, and invitation flows keep working when SMTP isn't
+// configured, e.g. in local development.
+type NoopMailer struct{}
+
+func (NoopMailer) Send(ctx context.Context, to, subject, htmlBody, textBody string) error {
+	log.Printf("email: (noop) to=%s subject=%q\n%s", to, subject, textBody)
+	return nil
+}
@@ -0,0 +1,143 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// TLSMode controls how SMTPMailer establishes transport security with the
+// relay.
+type TLSMode string
+
+const (
+	// TLSModeNone sends in the clear, or upgrades via STARTTLS if the
+	// server advertises it (net/smtp.SendMail does this automatically).
+	TLSModeNone TLSMode = "none"
+	// TLSModeSTARTTLS behaves the same as TLSModeNone: STARTTLS is
+	// negotiated opportunistically when offered.
+	TLSModeSTARTTLS TLSMode = "starttls"
+	// TLSModeTLS dials straight into TLS (e.g. port 465) instead of
+	// negotiating STARTTLS on a plaintext connection.
+	TLSModeTLS TLSMode = "tls"
+)
+
+// SMTPConfig holds the connection details for SMTPMailer.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	TLSMode  TLSMode
+}
+
+// SMTPMailer sends mail through an SMTP relay.
+type SMTPMailer struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPMailer wraps cfg as a Mailer.
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to, subject, htmlBody, textBody string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, m.cfg.Port)
+	msg := buildMIMEMessage(m.cfg.From, to, subject, htmlBody, textBody)
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	if m.cfg.TLSMode == TLSModeTLS {
+		return m.sendOverTLS(addr, auth, to, msg)
+	}
+
+	// net/smtp.SendMail opportunistically upgrades to STARTTLS when the
+	// server advertises it, which covers both TLSModeNone and
+	// TLSModeSTARTTLS.
+	if err := smtp.SendMail(addr, auth, m.cfg.From, []string{to}, msg); err != nil {
+		return fmt.Errorf("email: smtp send: %w", err)
+	}
+	return nil
+}
+
+// sendOverTLS is used for TLSModeTLS, where the connection must be
+// encrypted from the first byte (e.g. port 465) rather than upgraded via
+// STARTTLS, so it can't go through smtp.SendMail.
+func (m *SMTPMailer) sendOverTLS(addr string, auth smtp.Auth, to string, msg []byte) error {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: m.cfg.Host})
+	if err != nil {
+		return fmt.Errorf("email: tls dial: %w", err)
+	}
+	defer conn.Close()
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		return fmt.Errorf("email: smtp client: %w", err)
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("email: smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.cfg.From); err != nil {
+		return fmt.Errorf("email: MAIL FROM: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("email: RCPT TO: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("email: DATA: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("email: write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("email: close message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// buildMIMEMessage assembles a multipart/alternative message carrying both
+// the plain-text and HTML bodies, so mail clients without HTML rendering
+// still show something readable.
+func buildMIMEMessage(from, to, subject, htmlBody, textBody string) []byte {
+	const boundary = "studious-pancake-boundary"
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", boundary)
+
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n\r\n")
+	b.WriteString(textBody)
+	b.WriteString("\r\n\r\n")
+
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n")
+	b.WriteString(htmlBody)
+	b.WriteString("\r\n\r\n")
+
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+
+	return []byte(b.String())
+}
@@ -0,0 +1,33 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	htmlTemplates = template.Must(template.ParseFS(templateFS, "templates/*.html.tmpl"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt.tmpl"))
+)
+
+// Render executes the named template pair (e.g. "verify_email", matching
+// templates/verify_email.html.tmpl and templates/verify_email.txt.tmpl)
+// against data, returning the HTML and plain-text bodies for Mailer.Send.
+func Render(name string, data TemplateData) (htmlBody, textBody string, err error) {
+	var htmlBuf, textBuf bytes.Buffer
+
+	if err := htmlTemplates.ExecuteTemplate(&htmlBuf, name+".html.tmpl", data); err != nil {
+		return "", "", fmt.Errorf("email: render %s html: %w", name, err)
+	}
+	if err := textTemplates.ExecuteTemplate(&textBuf, name+".txt.tmpl", data); err != nil {
+		return "", "", fmt.Errorf("email: render %s text: %w", name, err)
+	}
+
+	return htmlBuf.String(), textBuf.String(), nil
+}
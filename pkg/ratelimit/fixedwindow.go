@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fixedWindowEntry tracks the count of requests seen in the current fixed
+// window, and when that window started.
+type fixedWindowEntry struct {
+	windowStart time.Time
+	window      time.Duration
+	count       int
+}
+
+// FixedWindowLimiter is an in-process Limiter using fixed windows: requests
+// are counted against the window that started at the last multiple of
+// window since Go's zero time (time.Time.Truncate), and the count resets the instant a new
+// window begins. Cheaper than a sliding log, at the cost of allowing up to
+// 2x limit requests across a window boundary.
+type FixedWindowLimiter struct {
+	shards []*fixedWindowShard
+	stopCh chan struct{}
+}
+
+type fixedWindowShard struct {
+	mu      sync.Mutex
+	entries map[string]*fixedWindowEntry
+}
+
+// NewFixedWindowLimiter creates a FixedWindowLimiter and starts its janitor
+// goroutine. Call Close when the limiter is no longer needed.
+func NewFixedWindowLimiter() *FixedWindowLimiter {
+	f := &FixedWindowLimiter{
+		shards: make([]*fixedWindowShard, memoryShardCount),
+		stopCh: make(chan struct{}),
+	}
+	for i := range f.shards {
+		f.shards[i] = &fixedWindowShard{entries: make(map[string]*fixedWindowEntry)}
+	}
+
+	go f.janitor()
+
+	return f
+}
+
+// Close stops the background janitor goroutine.
+func (f *FixedWindowLimiter) Close() {
+	close(f.stopCh)
+}
+
+func (f *FixedWindowLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	shard := f.shardFor(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := time.Now()
+	windowStart := now.Truncate(window)
+
+	entry, ok := shard.entries[key]
+	if !ok || entry.windowStart.Before(windowStart) {
+		entry = &fixedWindowEntry{windowStart: windowStart, window: window}
+		shard.entries[key] = entry
+	}
+
+	resetAt := entry.windowStart.Add(window)
+
+	if entry.count >= limit {
+		return Result{Allowed: false, Remaining: 0, ResetAt: resetAt, RetryAfter: resetAt.Sub(now)}, nil
+	}
+
+	entry.count++
+	return Result{Allowed: true, Remaining: limit - entry.count, ResetAt: resetAt}, nil
+}
+
+func (f *FixedWindowLimiter) shardFor(key string) *fixedWindowShard {
+	h := fnvHash(key)
+	return f.shards[h%uint32(len(f.shards))]
+}
+
+func (f *FixedWindowLimiter) janitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.sweep()
+		case <-f.stopCh:
+			return
+		}
+	}
+}
+
+func (f *FixedWindowLimiter) sweep() {
+	now := time.Now()
+	for _, shard := range f.shards {
+		shard.mu.Lock()
+		for key, entry := range shard.entries {
+			if now.Sub(entry.windowStart) > 2*entry.window {
+				delete(shard.entries, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
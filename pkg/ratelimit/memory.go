@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// memoryShardCount is the number of independently-locked buckets a
+// MemoryLimiter's keys are spread across, so two unrelated keys (e.g. two
+// different client IPs) don't contend on the same mutex.
+const memoryShardCount = 32
+
+// memoryEntry tracks the recent request timestamps for one key, plus the
+// window they were recorded against so the janitor can tell when the entry
+// has gone idle.
+type memoryEntry struct {
+	times  []time.Time
+	window time.Duration
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+// MemoryLimiter is an in-process sliding-window Limiter. It does not share
+// state across replicas and is reset on restart; use RedisLimiter when that
+// matters. A background goroutine periodically evicts keys that have gone
+// idle so the map doesn't grow without bound.
+type MemoryLimiter struct {
+	shards []*memoryShard
+	stopCh chan struct{}
+}
+
+// NewMemoryLimiter creates a MemoryLimiter and starts its janitor goroutine.
+// Call Close when the limiter is no longer needed to stop the janitor.
+func NewMemoryLimiter() *MemoryLimiter {
+	m := &MemoryLimiter{
+		shards: make([]*memoryShard, memoryShardCount),
+		stopCh: make(chan struct{}),
+	}
+	for i := range m.shards {
+		m.shards[i] = &memoryShard{entries: make(map[string]*memoryEntry)}
+	}
+
+	go m.janitor()
+
+	return m
+}
+
+// Close stops the background janitor goroutine.
+func (m *MemoryLimiter) Close() {
+	close(m.stopCh)
+}
+
+func (m *MemoryLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	shard := m.shardFor(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := time.Now()
+	windowStart := now.Add(-window)
+
+	entry, ok := shard.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		shard.entries[key] = entry
+	}
+	entry.window = window
+
+	valid := entry.times[:0]
+	for _, t := range entry.times {
+		if t.After(windowStart) {
+			valid = append(valid, t)
+		}
+	}
+
+	if len(valid) >= limit {
+		entry.times = valid
+		resetAt := valid[0].Add(window)
+		return Result{Allowed: false, Remaining: 0, ResetAt: resetAt, RetryAfter: resetAt.Sub(now)}, nil
+	}
+
+	entry.times = append(valid, now)
+	resetAt := now.Add(window)
+	if len(valid) > 0 {
+		resetAt = valid[0].Add(window)
+	}
+	return Result{Allowed: true, Remaining: limit - len(entry.times), ResetAt: resetAt}, nil
+}
+
+func (m *MemoryLimiter) shardFor(key string) *memoryShard {
+	return m.shards[fnvHash(key)%uint32(len(m.shards))]
+}
+
+// fnvHash hashes key for sharding keys across a fixed-size bucket array,
+// shared by every in-process Limiter implementation.
+func fnvHash(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// janitor periodically removes keys whose newest timestamp is older than
+// twice their window, since by then the key carries no remaining state.
+func (m *MemoryLimiter) janitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.sweep()
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+func (m *MemoryLimiter) sweep() {
+	now := time.Now()
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		for key, entry := range shard.entries {
+			if len(entry.times) == 0 {
+				delete(shard.entries, key)
+				continue
+			}
+			newest := entry.times[len(entry.times)-1]
+			if now.Sub(newest) > 2*entry.window {
+				delete(shard.entries, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
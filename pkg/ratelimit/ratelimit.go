@@ -0,0 +1,54 @@
+// Package ratelimit provides pluggable request rate limiting backends.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Result describes the outcome of a single Allow check, with enough detail
+// for a caller to populate standard X-RateLimit-* response headers without
+// re-deriving them.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	ResetAt    time.Time
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether a request identified by key is allowed within the
+// most recent window, given a maximum of limit requests per window.
+// Implementations must be safe for concurrent use.
+type Limiter interface {
+	// Allow reports whether the request is permitted, along with how many
+	// requests remain in the current window and when the window resets.
+	// When the request is denied, RetryAfter estimates how long the caller
+	// should wait before trying again.
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+}
+
+// Strategy selects the rate limiting algorithm a memory-backed Limiter
+// enforces. A Redis-backed Limiter always uses a sliding-window-counter,
+// since that's the one algorithm that reduces to a handful of atomic Redis
+// commands; Strategy only varies the in-process implementation.
+type Strategy string
+
+const (
+	StrategyFixedWindow   Strategy = "fixed-window"
+	StrategySlidingWindow Strategy = "sliding-window"
+	StrategyTokenBucket   Strategy = "token-bucket"
+)
+
+// NewMemoryLimiterForStrategy builds the in-process Limiter matching
+// strategy, defaulting to the sliding-window-log implementation for an
+// unrecognized value.
+func NewMemoryLimiterForStrategy(strategy Strategy) Limiter {
+	switch strategy {
+	case StrategyFixedWindow:
+		return NewFixedWindowLimiter()
+	case StrategyTokenBucket:
+		return NewTokenBucketLimiter()
+	default:
+		return NewMemoryLimiter()
+	}
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript atomically trims a key's sorted set to the current
+// window, records this request, and reports the post-trim cardinality, so
+// concurrent replicas sharing a Redis instance never race between the trim
+// and the count.
+//
+// KEYS[1] = the sorted set key
+// ARGV[1] = window start, in unix nanoseconds (members older than this are trimmed)
+// ARGV[2] = now, in unix nanoseconds (this request's score and member)
+// ARGV[3] = member suffix (so concurrent requests in the same nanosecond don't collide)
+// ARGV[4] = window, in seconds (for the key TTL)
+var slidingWindowScript = redis.NewScript(`
+	redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+	redis.call("ZADD", KEYS[1], ARGV[2], ARGV[2] .. "-" .. ARGV[3])
+	local count = redis.call("ZCARD", KEYS[1])
+	redis.call("EXPIRE", KEYS[1], ARGV[4])
+	return count
+`)
+
+// RedisLimiter is a sliding-window-counter Limiter backed by a Redis sorted
+// set per key, so limits are shared across every replica of the service.
+// Each request is scored by its arrival time; the trim, insert, count, and
+// TTL refresh run as a single Lua script so no other replica can observe a
+// half-updated key.
+type RedisLimiter struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisLimiter wraps an existing Redis client. Keys are namespaced under
+// prefix "ratelimit:" to avoid colliding with unrelated uses of the same
+// Redis instance.
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{client: client, prefix: "ratelimit:"}
+}
+
+func (r *RedisLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	redisKey := r.prefix + key
+	now := time.Now()
+
+	count, err := slidingWindowScript.Run(ctx, r.client,
+		[]string{redisKey},
+		now.Add(-window).UnixNano(),
+		now.UnixNano(),
+		randomMember(),
+		int(window.Seconds()),
+	).Int64()
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: redis script: %w", err)
+	}
+
+	if count <= int64(limit) {
+		return Result{Allowed: true, Remaining: limit - int(count), ResetAt: now.Add(window)}, nil
+	}
+
+	oldest, err := r.client.ZRangeWithScores(ctx, redisKey, 0, 0).Result()
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: redis zrange: %w", err)
+	}
+
+	resetAt := now.Add(window)
+	if len(oldest) > 0 {
+		resetAt = time.Unix(0, int64(oldest[0].Score)).Add(window)
+	}
+
+	return Result{Allowed: false, Remaining: 0, ResetAt: resetAt, RetryAfter: resetAt.Sub(now)}, nil
+}
+
+func randomMember() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
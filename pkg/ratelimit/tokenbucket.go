@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucketEntry holds a key's current token count and when it was last
+// refilled, so refills can be computed lazily on the next Allow call
+// instead of needing a ticking goroutine per key.
+type tokenBucketEntry struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// TokenBucketLimiter is an in-process Limiter that models limit as the
+// bucket capacity and limit/window as the refill rate, so a burst up to
+// limit is allowed immediately after idle time, then smooths out to the
+// steady-state rate. Unlike the window-based limiters, a single denied
+// request doesn't block the next one if enough time has passed to refill
+// a token.
+type TokenBucketLimiter struct {
+	shards []*tokenBucketShard
+	stopCh chan struct{}
+}
+
+type tokenBucketShard struct {
+	mu      sync.Mutex
+	entries map[string]*tokenBucketEntry
+}
+
+// NewTokenBucketLimiter creates a TokenBucketLimiter and starts its janitor
+// goroutine. Call Close when the limiter is no longer needed.
+func NewTokenBucketLimiter() *TokenBucketLimiter {
+	t := &TokenBucketLimiter{
+		shards: make([]*tokenBucketShard, memoryShardCount),
+		stopCh: make(chan struct{}),
+	}
+	for i := range t.shards {
+		t.shards[i] = &tokenBucketShard{entries: make(map[string]*tokenBucketEntry)}
+	}
+
+	go t.janitor()
+
+	return t
+}
+
+// Close stops the background janitor goroutine.
+func (t *TokenBucketLimiter) Close() {
+	close(t.stopCh)
+}
+
+func (t *TokenBucketLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	shard := t.shardFor(key)
+	refillRate := float64(limit) / window.Seconds()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := time.Now()
+
+	entry, ok := shard.entries[key]
+	if !ok {
+		entry = &tokenBucketEntry{tokens: float64(limit), lastRefill: now}
+		shard.entries[key] = entry
+	} else {
+		elapsed := now.Sub(entry.lastRefill).Seconds()
+		entry.tokens = minFloat(float64(limit), entry.tokens+elapsed*refillRate)
+		entry.lastRefill = now
+	}
+
+	resetAt := now.Add(time.Duration((float64(limit) - entry.tokens) / refillRate * float64(time.Second)))
+
+	if entry.tokens < 1 {
+		retryAfter := time.Duration((1 - entry.tokens) / refillRate * float64(time.Second))
+		return Result{Allowed: false, Remaining: 0, ResetAt: resetAt, RetryAfter: retryAfter}, nil
+	}
+
+	entry.tokens--
+	return Result{Allowed: true, Remaining: int(entry.tokens), ResetAt: resetAt}, nil
+}
+
+func (t *TokenBucketLimiter) shardFor(key string) *tokenBucketShard {
+	return t.shards[fnvHash(key)%uint32(len(t.shards))]
+}
+
+func (t *TokenBucketLimiter) janitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.sweep()
+		case <-t.stopCh:
+			return
+		}
+	}
+}
+
+func (t *TokenBucketLimiter) sweep() {
+	now := time.Now()
+	for _, shard := range t.shards {
+		shard.mu.Lock()
+		for key, entry := range shard.entries {
+			if now.Sub(entry.lastRefill) > 2*time.Hour {
+				delete(shard.entries, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
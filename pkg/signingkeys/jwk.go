@@ -0,0 +1,42 @@
+package signingkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// JWK renders the key's public half as a JSON Web Key (RFC 7517), suitable
+// for inclusion in a JWKS document.
+func (k *Key) JWK() (map[string]interface{}, error) {
+	switch pub := k.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]interface{}{
+			"kty": "RSA",
+			"kid": k.KID,
+			"alg": string(k.Algorithm),
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		x := make([]byte, size)
+		y := make([]byte, size)
+		pub.X.FillBytes(x)
+		pub.Y.FillBytes(y)
+		return map[string]interface{}{
+			"kty": "EC",
+			"kid": k.KID,
+			"alg": string(k.Algorithm),
+			"use": "sig",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(x),
+			"y":   base64.RawURLEncoding.EncodeToString(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("signingkeys: unsupported public key type %T", pub)
+	}
+}
@@ -0,0 +1,162 @@
+package signingkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RotatingProvider is an in-memory Provider. Keys live only for the life of
+// the process; a restart starts a fresh key set, which is fine for access
+// tokens short-lived enough to outlive a single deploy cycle.
+type RotatingProvider struct {
+	algorithm Algorithm
+	overlap   time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]*Key
+	activeKID string
+
+	stopCh chan struct{}
+}
+
+// NewRotatingProvider generates an initial key for algorithm and starts the
+// background janitor that drops keys once their retirement overlap window
+// (how long an in-flight token may still validate after rotation) elapses.
+func NewRotatingProvider(algorithm Algorithm, overlap time.Duration) (*RotatingProvider, error) {
+	p := &RotatingProvider{
+		algorithm: algorithm,
+		overlap:   overlap,
+		keys:      make(map[string]*Key),
+		stopCh:    make(chan struct{}),
+	}
+
+	if _, err := p.Rotate(); err != nil {
+		return nil, err
+	}
+
+	go p.janitor()
+
+	return p, nil
+}
+
+// Close stops the background janitor goroutine.
+func (p *RotatingProvider) Close() {
+	close(p.stopCh)
+}
+
+func (p *RotatingProvider) ActiveKey() (*Key, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	key, ok := p.keys[p.activeKID]
+	if !ok {
+		return nil, fmt.Errorf("signingkeys: no active key")
+	}
+	return key, nil
+}
+
+func (p *RotatingProvider) LookupKey(kid string) (*Key, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *RotatingProvider) PublicKeys() []*Key {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(p.keys))
+	for _, key := range p.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (p *RotatingProvider) Rotate() (*Key, error) {
+	key, err := generateKey(p.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if prev, ok := p.keys[p.activeKID]; ok {
+		prev.RetireAt = time.Now().Add(p.overlap)
+	}
+	p.keys[key.KID] = key
+	p.activeKID = key.KID
+	p.mu.Unlock()
+
+	return key, nil
+}
+
+// janitor periodically drops retired keys whose overlap window has
+// elapsed, so the published JWKS and accepted kid set don't grow forever.
+func (p *RotatingProvider) janitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.sweep()
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+func (p *RotatingProvider) sweep() {
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for kid, key := range p.keys {
+		if kid == p.activeKID {
+			continue
+		}
+		if !key.RetireAt.IsZero() && now.After(key.RetireAt) {
+			delete(p.keys, kid)
+		}
+	}
+}
+
+func generateKey(algorithm Algorithm) (*Key, error) {
+	kid, err := generateKID()
+	if err != nil {
+		return nil, err
+	}
+
+	switch algorithm {
+	case RS256:
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("signingkeys: generate RSA key: %w", err)
+		}
+		return &Key{KID: kid, Algorithm: algorithm, PrivateKey: priv, CreatedAt: time.Now()}, nil
+	case ES256:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("signingkeys: generate EC key: %w", err)
+		}
+		return &Key{KID: kid, Algorithm: algorithm, PrivateKey: priv, CreatedAt: time.Now()}, nil
+	default:
+		return nil, fmt.Errorf("signingkeys: unsupported algorithm %q", algorithm)
+	}
+}
+
+func generateKID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("signingkeys: generate kid: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,75 @@
+// Package signingkeys provides a pluggable, rotating source of asymmetric
+// JWT signing keys, so access tokens can be verified by a frontend or
+// downstream service via a published JWKS instead of a shared HMAC secret.
+package signingkeys
+
+import (
+	"crypto"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithm identifies a supported signing algorithm.
+type Algorithm string
+
+const (
+	RS256 Algorithm = "RS256"
+	ES256 Algorithm = "ES256"
+)
+
+// SigningMethod returns the golang-jwt method for alg, or nil if alg isn't
+// supported.
+func (alg Algorithm) SigningMethod() jwt.SigningMethod {
+	switch alg {
+	case RS256:
+		return jwt.SigningMethodRS256
+	case ES256:
+		return jwt.SigningMethodES256
+	default:
+		return nil
+	}
+}
+
+// Key is one entry in a rotating key set: a private key, the kid it signs
+// under, and (once superseded by a newer active key) the time it should be
+// dropped from the public set entirely.
+type Key struct {
+	KID        string
+	Algorithm  Algorithm
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+	// RetireAt is zero while the key is active. Rotate sets it to the end
+	// of the key's overlap window; the key is dropped from the set once
+	// that time has passed.
+	RetireAt time.Time
+}
+
+// Public returns the key's public half, for JWKS publication and for
+// verifying tokens signed with it.
+func (k *Key) Public() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
+
+// SigningMethod returns the golang-jwt method for this key's algorithm.
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	return k.Algorithm.SigningMethod()
+}
+
+// Provider is a pluggable source of signing keys: one currently-active key
+// for signing new tokens, and a broader set (including recently-retired
+// keys still inside their overlap window) for verifying tokens that may
+// have been signed moments before a rotation.
+type Provider interface {
+	// ActiveKey returns the key new tokens should be signed with.
+	ActiveKey() (*Key, error)
+	// LookupKey returns the key for kid, if it is still known (active or
+	// within its retirement overlap window).
+	LookupKey(kid string) (*Key, bool)
+	// PublicKeys returns every key that should currently be published in
+	// the JWKS, including keys inside their retirement overlap window.
+	PublicKeys() []*Key
+	// Rotate generates a new active key, retiring the previous one after
+	// the provider's configured overlap window, and returns the new key.
+	Rotate() (*Key, error)
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image/png"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
@@ -14,76 +22,183 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
 	"gorm.io/gorm"
 
 	"github.com/shahid-io/studious-pancake/libs/domain/auth"
 	"github.com/shahid-io/studious-pancake/libs/domain/user"
+	"github.com/shahid-io/studious-pancake/pkg/activity"
 	"github.com/shahid-io/studious-pancake/pkg/config"
 	"github.com/shahid-io/studious-pancake/pkg/database"
+	"github.com/shahid-io/studious-pancake/pkg/email"
+	"github.com/shahid-io/studious-pancake/pkg/ratelimit"
+	"github.com/shahid-io/studious-pancake/pkg/signingkeys"
 )
 
 var (
-	db  *gorm.DB
-	cfg *config.Config
+	db                 *gorm.DB
+	cfg                *config.Config
+	authLimiter        ratelimit.Limiter
+	mailer             email.Mailer
+	emailQueue         chan emailJob
+	signingKeyProvider signingkeys.Provider
+
+	// activitySink mirrors every recorded UserActivity somewhere beyond
+	// Postgres. Empty by default; a deployment that wants activities on a
+	// message bus assigns a FanOut including that sink during startup.
+	activitySink activity.Sink = activity.FanOut{}
 )
 
-// Rate limiting structures
-type RateLimiter struct {
-	requests map[string][]time.Time
-	mutex    sync.RWMutex
+// emailWorkerCount is the number of goroutines draining emailQueue, so SMTP
+// latency never blocks the HTTP handler that triggered the send.
+const emailWorkerCount = 4
+
+// emailQueueSize bounds how many sends can be pending before queueEmail
+// starts dropping, so a stalled SMTP relay can't grow memory unbounded.
+const emailQueueSize = 256
+
+type emailJob struct {
+	to       string
+	template string
+	subject  string
+	data     email.TemplateData
+}
+
+// newMailer selects NoopMailer when SMTP isn't configured, so registration,
+// password-reset, and invitation flows keep working in local development.
+func newMailer(cfg *config.Config) email.Mailer {
+	if cfg.SMTPHost == "" {
+		return email.NoopMailer{}
+	}
+	return email.NewSMTPMailer(email.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+		TLSMode:  email.TLSMode(cfg.SMTPTLSMode),
+	})
 }
 
-func NewRateLimiter() *RateLimiter {
-	return &RateLimiter{
-		requests: make(map[string][]time.Time),
+// startEmailWorkers launches the goroutine pool that drains emailQueue.
+func startEmailWorkers() {
+	emailQueue = make(chan emailJob, emailQueueSize)
+	for i := 0; i < emailWorkerCount; i++ {
+		go emailWorker()
 	}
 }
 
-func (rl *RateLimiter) IsAllowed(ip string, limit int, window time.Duration) bool {
-	rl.mutex.Lock()
-	defer rl.mutex.Unlock()
+func emailWorker() {
+	for job := range emailQueue {
+		htmlBody, textBody, err := email.Render(job.template, job.data)
+		if err != nil {
+			log.Printf("email: failed to render %s for %s: %v", job.template, job.to, err)
+			continue
+		}
 
-	now := time.Now()
-	windowStart := now.Add(-window)
+		sendErr := mailer.Send(context.Background(), job.to, job.subject, htmlBody, textBody)
 
-	// Clean old requests
-	if times, exists := rl.requests[ip]; exists {
-		var validTimes []time.Time
-		for _, t := range times {
-			if t.After(windowStart) {
-				validTimes = append(validTimes, t)
-			}
+		delivery := email.Delivery{
+			To:        job.to,
+			Template:  job.template,
+			MessageID: generateRandomToken(),
+			Success:   sendErr == nil,
+		}
+		if sendErr != nil {
+			delivery.Error = sendErr.Error()
+			log.Printf("email: failed to send %s to %s: %v", job.template, job.to, sendErr)
+		} else {
+			delivery.SentAt = time.Now()
+		}
+		if err := db.Create(&delivery).Error; err != nil {
+			log.Printf("email: failed to record delivery for %s: %v", job.to, err)
 		}
-		rl.requests[ip] = validTimes
 	}
+}
 
-	// Check if limit exceeded
-	if len(rl.requests[ip]) >= limit {
-		return false
+// queueEmail enqueues a templated send without blocking the caller. If the
+// queue is full (the SMTP relay is stalled), the send is dropped and
+// logged rather than backing up the request path.
+func queueEmail(to, template, subject string, data email.TemplateData) {
+	select {
+	case emailQueue <- emailJob{to: to, template: template, subject: subject, data: data}:
+	default:
+		log.Printf("email: queue full, dropping %s email to %s", template, to)
 	}
-
-	// Add current request
-	rl.requests[ip] = append(rl.requests[ip], now)
-	return true
 }
 
-var authRateLimiter = NewRateLimiter()
+// newRateLimiter builds the configured rate limiting backend. "redis" shares
+// limits across every replica of the service via a sliding-window-counter;
+// "memory" is the fallback for single-instance or local development use,
+// and its algorithm is further selected by RateLimitStrategy.
+func newRateLimiter(cfg *config.Config) ratelimit.Limiter {
+	switch cfg.RateLimitBackend {
+	case "redis":
+		client := redis.NewClient(&redis.Options{Addr: cfg.RedisURL})
+		return ratelimit.NewRedisLimiter(client)
+	default:
+		return ratelimit.NewMemoryLimiterForStrategy(ratelimit.Strategy(cfg.RateLimitStrategy))
+	}
+}
 
 func main() {
 	// Load configuration
-	cfg = config.Load()
+	var err error
+	cfg, err = config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
 
 	// Connect to database with retry
-	db = database.Connect(cfg.DatabaseURL)
+	dbConn, err := database.Connect(database.Config{
+		Driver: "postgres",
+		DSN:    cfg.DatabaseURL,
+	})
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	db = dbConn
+
+	authLimiter = newRateLimiter(cfg)
+	mailer = newMailer(cfg)
+	startEmailWorkers()
+
+	provider, err := signingkeys.NewRotatingProvider(signingkeys.Algorithm(cfg.JWTAlgorithm), cfg.JWTKeyOverlap)
+	if err != nil {
+		log.Fatal("Failed to initialize signing keys:", err)
+	}
+	signingKeyProvider = provider
+
+	encryptionKey, err := hex.DecodeString(cfg.EncryptionKey)
+	if err != nil {
+		log.Fatal("Failed to decode EncryptionKey:", err)
+	}
+	if err := user.SetEncryptionKey(encryptionKey); err != nil {
+		log.Fatal("Failed to install encryption key:", err)
+	}
 
 	// Auto-migrate models
-	if err := db.AutoMigrate(
+	if err := database.AutoMigrate(db,
 		&user.User{},
 		&user.UserProfile{},
 		&user.UserSession{},
 		&user.UserVerification{},
 		&user.UserActivity{},
+		&user.UserInvitation{},
+		&user.UserMFA{},
+		&user.UserRecoveryCode{},
+		&user.UserIdentity{},
+		&user.UsedReauthToken{},
+		&user.UserToken{},
+		&user.TermsOfService{},
+		&user.UserTermsAcceptance{},
+		&email.Delivery{},
 	); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
@@ -92,9 +207,14 @@ func main() {
 	router := gin.Default()
 	router.GET("/", getPumpkin)
 	// Middleware
-	router.Use(CORSMiddleware())
+	router.Use(CORSMiddleware(corsConfigFromEnv(cfg)))
 	router.Use(LoggerMiddleware())
 
+	// OIDC discovery routes live at the well-known top-level paths, not
+	// nested under /api/v1/auth, per RFC 8615.
+	router.GET("/.well-known/openid-configuration", openIDConfigurationHandler)
+	router.GET("/.well-known/jwks.json", jwksHandler)
+
 	// Public routes
 	public := router.Group("/api/v1/auth")
 	{
@@ -106,6 +226,12 @@ func main() {
 		public.POST("/verify-email", RateLimitMiddleware(10, time.Minute*5), verifyEmailHandler)
 		public.GET("/verify-email", RateLimitMiddleware(10, time.Minute*5), verifyEmailHandler) // Allow GET for email links
 		public.POST("/resend-verification", RateLimitMiddleware(3, time.Minute*10), resendVerificationHandler)
+		public.POST("/email/confirm-change", RateLimitMiddleware(10, time.Minute*5), confirmEmailChangeHandler)
+		public.POST("/accept-invitation", RateLimitMiddleware(5, time.Minute*10), acceptInvitationHandler)
+		public.POST("/mfa/login-verify", RateLimitMiddleware(10, time.Minute*5), mfaLoginVerifyHandler)
+		public.GET("/oauth/:provider/login", RateLimitMiddleware(20, time.Minute*10), oauthLoginHandler)
+		public.GET("/oauth/:provider/callback", RateLimitMiddleware(20, time.Minute*10), oauthCallbackHandler)
+		public.POST("/oauth/login", RateLimitMiddleware(20, time.Minute*10), oidcLoginHandler)
 		public.GET("/health", healthHandler)
 	}
 
@@ -115,7 +241,40 @@ func main() {
 	{
 		protected.GET("/profile", profileHandler)
 		protected.POST("/logout", RateLimitMiddleware(10, time.Minute*5), logoutHandler)
-		protected.POST("/change-password", RateLimitMiddleware(3, time.Minute*10), changePasswordHandler)
+		protected.POST("/reauthenticate", RateLimitMiddleware(5, time.Minute*10), reauthenticateHandler)
+		protected.POST("/change-password", RateLimitMiddleware(3, time.Minute*10), RequireReauthMiddleware(), changePasswordHandler)
+		protected.POST("/email/request-change", RateLimitMiddleware(3, time.Minute*10), RequireReauthMiddleware(), requestEmailChangeHandler)
+		protected.POST("/mfa/enroll", RateLimitMiddleware(5, time.Minute*10), mfaEnrollHandler)
+		protected.POST("/mfa/verify", RateLimitMiddleware(10, time.Minute*10), mfaVerifyHandler)
+		protected.POST("/mfa/disable", RateLimitMiddleware(5, time.Minute*10), RequireReauthMiddleware(), mfaDisableHandler)
+		protected.GET("/userinfo", userinfoHandler)
+		protected.POST("/identities", RateLimitMiddleware(10, time.Minute*10), linkIdentityHandler)
+		protected.DELETE("/identities/:provider", RateLimitMiddleware(10, time.Minute*10), unlinkIdentityHandler)
+		protected.POST("/terms/accept", RateLimitMiddleware(10, time.Minute*10), acceptTermsHandler)
+		protected.GET("/activities", activitiesHandler)
+		protected.GET("/sessions", sessionsHandler)
+		protected.DELETE("/sessions/:id", revokeSessionHandler)
+		protected.DELETE("/sessions", revokeOtherSessionsHandler)
+	}
+
+	// Admin-only routes
+	admin := router.Group("/api/v1/auth/admin")
+	admin.Use(AuthMiddleware(), AdminMiddleware())
+	{
+		admin.POST("/invite", RateLimitMiddleware(20, time.Minute*10), inviteUserHandler)
+		admin.POST("/rotate-keys", rotateKeysHandler)
+		admin.POST("/users/:id/revoke", adminRevokeUserHandler)
+	}
+
+	// Internal service-to-service routes, authenticated with a shared-secret
+	// RPC token instead of a user-facing session. Unregistered when no
+	// secret is configured.
+	if cfg.RPCAuthSecret != "" {
+		rpc := router.Group("/internal/rpc")
+		rpc.Use(RPCAuthMiddleware(cfg.RPCAuthSecret))
+		{
+			rpc.GET("/ping", rpcPingHandler)
+		}
 	}
 
 	// Start HTTP server
@@ -213,6 +372,26 @@ func validatePasswordStrength(password string) error {
 }
 
 // Handlers
+// activeTermsOfService returns the currently active TermsOfService, or nil
+// if none has been configured yet (in which case acceptance isn't
+// enforced at all).
+func activeTermsOfService() (*user.TermsOfService, error) {
+	var terms user.TermsOfService
+	if err := db.Where("is_active = ?", true).First(&terms).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &terms, nil
+}
+
+// hasAcceptedTerms reports whether userID has an acceptance row for termsID.
+func hasAcceptedTerms(userID string, termsID uint) bool {
+	var acceptance user.UserTermsAcceptance
+	return db.Where("user_id = ? AND terms_id = ?", userID, termsID).First(&acceptance).Error == nil
+}
+
 func registerHandler(c *gin.Context) {
 	var req auth.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -233,6 +412,16 @@ func registerHandler(c *gin.Context) {
 		return
 	}
 
+	activeTerms, err := activeTermsOfService()
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to check terms of service")
+		return
+	}
+	if activeTerms != nil && req.AcceptedTermsVersion != activeTerms.Version {
+		sendErrorResponse(c, http.StatusBadRequest, "You must accept the current terms of service to register")
+		return
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -268,31 +457,41 @@ func registerHandler(c *gin.Context) {
 
 	// Create verification record
 	verification := user.UserVerification{
-		UserID:     fmt.Sprintf("%d", newUser.ID),
-		EmailToken: generateRandomToken(),
-		PhoneToken: generateRandomToken(),
+		UserID: fmt.Sprintf("%d", newUser.ID),
 	}
 	db.Create(&verification)
 
-	// Generate JWT token
-	token, expiresAt, err := generateJWTToken(newUser)
+	if activeTerms != nil {
+		db.Create(&user.UserTermsAcceptance{
+			UserID:     fmt.Sprintf("%d", newUser.ID),
+			TermsID:    activeTerms.ID,
+			AcceptedAt: time.Now(),
+			IPAddress:  c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+		})
+	}
+
+	emailToken, _, err := issueUserToken(fmt.Sprintf("%d", newUser.ID), user.TokenKindEmailConfirm, 24*time.Hour, "", c.ClientIP())
 	if err != nil {
-		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to create verification token")
 		return
 	}
 
-	// Generate refresh token and create session
-	refreshSession, err := generateRefreshToken(newUser.ID, c.ClientIP(), c.Request.UserAgent())
+	// Generate access + refresh tokens and the session backing them
+	token, expiresAt, refreshSession, err := issueTokens(newUser, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
-		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate refresh token")
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate tokens")
 		return
 	}
 
 	// Log activity
-	logUserActivity(newUser.ID, "register", c)
+	logUserActivity(newUser.ID, "register", nil, c)
 
-	// TODO: Send verification email
-	log.Printf("Email verification token for %s: %s", newUser.Email, verification.EmailToken)
+	queueEmail(newUser.Email, "verify_email", "Verify your email address", email.TemplateData{
+		AppName:   cfg.AppName,
+		ActionURL: fmt.Sprintf("%s/api/v1/auth/verify-email?token=%s", cfg.OAuthRedirectBaseURL, emailToken),
+		User:      newUser,
+	})
 
 	loginResponse := auth.LoginResponse{
 		AccessToken:  token,
@@ -303,6 +502,7 @@ func registerHandler(c *gin.Context) {
 		User:         newUser,
 	}
 
+	setAuthCookies(c, token, expiresAt, refreshSession.Token, refreshSession.ExpiresAt)
 	sendSuccessResponse(c, http.StatusCreated, loginResponse, "User registered successfully")
 }
 
@@ -336,21 +536,36 @@ func loginHandler(c *gin.Context) {
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(req.Password)); err != nil {
 		logFailedAttempt(req.Email, c.ClientIP())
+		attemptCount := countRecentLoginFailures(foundUser.ID) + 1
+		logUserActivity(foundUser.ID, user.ActivityLoginFailure, user.LoginFailurePayload{
+			Reason:       "invalid_password",
+			AttemptCount: attemptCount,
+		}, c)
 		sendErrorResponse(c, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
 
-	// Generate JWT token
-	token, expiresAt, err := generateJWTToken(foundUser)
-	if err != nil {
-		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+	// If MFA is enrolled, the password alone isn't enough: hand back a
+	// short-lived challenge token instead of real tokens
+	var mfa user.UserMFA
+	if err := db.Where("user_id = ? AND enabled = ?", fmt.Sprintf("%d", foundUser.ID), true).First(&mfa).Error; err == nil {
+		mfaToken, err := generateMFAToken(foundUser.ID)
+		if err != nil {
+			sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate MFA challenge")
+			return
+		}
+
+		sendSuccessResponse(c, http.StatusOK, auth.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, "MFA verification required")
 		return
 	}
 
-	// Generate refresh token and create session
-	refreshSession, err := generateRefreshToken(foundUser.ID, c.ClientIP(), c.Request.UserAgent())
+	// Generate access + refresh tokens and the session backing them
+	token, expiresAt, refreshSession, err := issueTokens(foundUser, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
-		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate refresh token")
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate tokens")
 		return
 	}
 
@@ -359,7 +574,7 @@ func loginHandler(c *gin.Context) {
 	db.Save(&foundUser)
 
 	// Log activity
-	logUserActivity(foundUser.ID, "login", c)
+	logUserActivity(foundUser.ID, user.ActivityLoginSuccess, nil, c)
 
 	loginResponse := auth.LoginResponse{
 		AccessToken:  token,
@@ -370,6 +585,13 @@ func loginHandler(c *gin.Context) {
 		User:         foundUser,
 	}
 
+	if activeTerms, err := activeTermsOfService(); err == nil && activeTerms != nil {
+		if !hasAcceptedTerms(fmt.Sprintf("%d", foundUser.ID), activeTerms.ID) {
+			loginResponse.TermsAcceptanceRequired = activeTerms
+		}
+	}
+
+	setAuthCookies(c, token, expiresAt, refreshSession.Token, refreshSession.ExpiresAt)
 	sendSuccessResponse(c, http.StatusOK, loginResponse, "Login successful")
 }
 
@@ -444,32 +666,24 @@ func refreshTokenHandler(c *gin.Context) {
 		return
 	}
 
-	// Generate new access token
-	accessToken, expiresAt, err := generateJWTToken(user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "Failed to generate access token",
-		})
-		return
-	}
-
-	// Rotate refresh token (invalidate old, create new)
+	// Rotate refresh token (invalidate old, create new) and issue a fresh
+	// access token bound to the new session
 	session.IsActive = false
 	db.Save(&session)
 
-	newRefreshSession, err := generateRefreshToken(user.ID, c.ClientIP(), c.Request.UserAgent())
+	accessToken, expiresAt, newRefreshSession, err := issueTokens(user, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "Failed to generate new refresh token",
+			"error":   "Failed to generate new tokens",
 		})
 		return
 	}
 
 	// Log activity
-	logUserActivity(user.ID, "token_refresh", c)
+	logUserActivity(user.ID, "token_refresh", nil, c)
 
+	setAuthCookies(c, accessToken, expiresAt, newRefreshSession.Token, newRefreshSession.ExpiresAt)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": auth.LoginResponse{
@@ -527,7 +741,7 @@ func logoutHandler(c *gin.Context) {
 	// Convert userID to uint for logging
 	userIDStr := fmt.Sprintf("%v", userID)
 	if userIDUint, err := strconv.ParseUint(userIDStr, 10, 32); err == nil {
-		logUserActivity(uint(userIDUint), "logout", c)
+		logUserActivity(uint(userIDUint), "logout", nil, c)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -536,6 +750,134 @@ func logoutHandler(c *gin.Context) {
 	})
 }
 
+// activitiesHandler returns a paginated page of the authenticated user's
+// own activity feed, newest first, for a security-review "recent activity"
+// view. ?kind may repeat to filter to specific ActivityKinds; ?since/
+// ?until are RFC3339 timestamps; ?cursor/?limit page through the results.
+func activitiesHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var kinds []user.ActivityKind
+	for _, k := range c.QueryArray("kind") {
+		kinds = append(kinds, user.ActivityKind(k))
+	}
+
+	var since, until time.Time
+	if raw := c.Query("since"); raw != "" {
+		if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+			since = parsed
+		}
+	}
+	if raw := c.Query("until"); raw != "" {
+		if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+			until = parsed
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	cursor, _ := strconv.ParseUint(c.Query("cursor"), 10, 32)
+
+	activities, nextCursor, err := queryActivities(fmt.Sprintf("%v", userID), kinds, since, until, limit, uint(cursor))
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load activity feed")
+		return
+	}
+
+	sendSuccessResponse(c, http.StatusOK, gin.H{
+		"activities":  activities,
+		"next_cursor": nextCursor,
+	}, "")
+}
+
+// sessionsHandler lists the authenticated user's active login sessions,
+// parsing each one's User-Agent into a browser/OS/device label for display.
+func sessionsHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	currentSessionID, _ := c.Get("sessionID")
+
+	var sessions []user.UserSession
+	if err := db.Where("user_id = ? AND is_active = ?", fmt.Sprintf("%v", userID), true).
+		Order("created_at DESC").Find(&sessions).Error; err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load sessions")
+		return
+	}
+
+	responses := make([]auth.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		browser, os, device := parseUserAgent(session.UserAgent)
+		responses = append(responses, auth.SessionResponse{
+			ID:        session.ID,
+			IPAddress: session.IPAddress,
+			Browser:   browser,
+			OS:        os,
+			Device:    device,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Current:   currentSessionID == session.ID,
+		})
+	}
+
+	sendSuccessResponse(c, http.StatusOK, responses, "")
+}
+
+// revokeSessionHandler revokes one of the authenticated user's sessions by
+// ID, e.g. from a "sign out this device" button in the session list.
+func revokeSessionHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var session user.UserSession
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), fmt.Sprintf("%v", userID)).
+		First(&session).Error; err != nil {
+		sendErrorResponse(c, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	session.IsActive = false
+	if err := db.Save(&session).Error; err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+	invalidateSessionCache(session.ID)
+
+	if userIDUint, err := strconv.ParseUint(fmt.Sprintf("%v", userID), 10, 32); err == nil {
+		logUserActivity(uint(userIDUint), user.ActivitySessionRevoked, user.SessionRevokedPayload{
+			SessionID: session.ID,
+		}, c)
+	}
+
+	sendSuccessResponse(c, http.StatusOK, nil, "Session revoked")
+}
+
+// revokeOtherSessionsHandler revokes every active session for the
+// authenticated user except the one the request itself is using.
+func revokeOtherSessionsHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	currentSessionID, _ := c.Get("sessionID")
+
+	var sessions []user.UserSession
+	if err := db.Where("user_id = ? AND is_active = ? AND id <> ?",
+		fmt.Sprintf("%v", userID), true, currentSessionID).Find(&sessions).Error; err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	db.Model(&user.UserSession{}).
+		Where("user_id = ? AND is_active = ? AND id <> ?", fmt.Sprintf("%v", userID), true, currentSessionID).
+		Update("is_active", false)
+
+	for _, session := range sessions {
+		invalidateSessionCache(session.ID)
+	}
+
+	if userIDUint, err := strconv.ParseUint(fmt.Sprintf("%v", userID), 10, 32); err == nil {
+		logUserActivity(uint(userIDUint), user.ActivitySessionRevoked, user.SessionRevokedPayload{
+			ByAdmin: false,
+		}, c)
+	}
+
+	sendSuccessResponse(c, http.StatusOK, nil, "Other sessions revoked")
+}
+
 func forgotPasswordHandler(c *gin.Context) {
 	var req auth.ForgotPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -567,35 +909,25 @@ func forgotPasswordHandler(c *gin.Context) {
 		return
 	}
 
-	// Generate password reset token
-	resetToken := generateRandomToken()
-	resetExpiry := time.Now().Add(1 * time.Hour) // Token expires in 1 hour
-
-	// Update or create verification record
-	verification := user.UserVerification{
-		UserID:              fmt.Sprintf("%d", foundUser.ID),
-		PasswordResetToken:  resetToken,
-		PasswordResetExpiry: resetExpiry,
-	}
-
-	// First try to update existing record
-	result := db.Model(&verification).Where("user_id = ?", fmt.Sprintf("%d", foundUser.ID)).
-		Updates(map[string]interface{}{
-			"password_reset_token":  resetToken,
-			"password_reset_expiry": resetExpiry,
+	// Generate a password reset token, valid for 1 hour
+	resetToken, tokenRow, err := issueUserToken(fmt.Sprintf("%d", foundUser.ID), user.TokenKindPasswordReset, time.Hour, "", c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to create reset token",
 		})
-
-	if result.RowsAffected == 0 {
-		// Create new verification record if none exists
-		db.Create(&verification)
+		return
 	}
 
 	// Log activity
-	logUserActivity(foundUser.ID, "password_reset_requested", c)
+	logUserActivity(foundUser.ID, user.ActivityPasswordResetRequested, nil, c)
 
-	// TODO: Send email with reset link
-	// For now, log the token (in production, send email)
-	log.Printf("Password reset token for %s: %s", foundUser.Email, resetToken)
+	queueEmail(foundUser.Email, "password_reset", "Reset your password", email.TemplateData{
+		AppName:   cfg.AppName,
+		ActionURL: fmt.Sprintf("%s/reset-password?token=%s", cfg.OAuthRedirectBaseURL, resetToken),
+		ExpiresAt: tokenRow.ExpiresAt,
+		User:      foundUser,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -623,10 +955,9 @@ func resetPasswordHandler(c *gin.Context) {
 		return
 	}
 
-	// Find verification record with the reset token
-	var verification user.UserVerification
-	if err := db.Where("password_reset_token = ? AND password_reset_expiry > ?",
-		req.Token, time.Now()).First(&verification).Error; err != nil {
+	// Find the reset token
+	userToken, err := consumeUserToken(req.Token, user.TokenKindPasswordReset)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "Invalid or expired reset token",
@@ -636,7 +967,7 @@ func resetPasswordHandler(c *gin.Context) {
 
 	// Find the user
 	var foundUser user.User
-	if err := db.First(&foundUser, verification.UserID).Error; err != nil {
+	if err := db.First(&foundUser, userToken.UserID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"error":   "User not found",
@@ -656,6 +987,7 @@ func resetPasswordHandler(c *gin.Context) {
 
 	// Update user password
 	foundUser.Password = string(hashedPassword)
+	foundUser.PasswordChangedAt = time.Now()
 	if err := db.Save(&foundUser).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -664,17 +996,17 @@ func resetPasswordHandler(c *gin.Context) {
 		return
 	}
 
-	// Clear the reset token
-	verification.PasswordResetToken = ""
-	verification.PasswordResetExpiry = time.Time{}
-	db.Save(&verification)
-
 	// Invalidate all user sessions for security
-	db.Model(&user.UserSession{}).Where("user_id = ?", verification.UserID).
+	db.Model(&user.UserSession{}).Where("user_id = ?", userToken.UserID).
 		Update("is_active", false)
 
 	// Log activity
-	logUserActivity(foundUser.ID, "password_reset_completed", c)
+	logUserActivity(foundUser.ID, user.ActivityPasswordChanged, nil, c)
+
+	queueEmail(foundUser.Email, "password_changed_notification", "Your password was changed", email.TemplateData{
+		AppName: cfg.AppName,
+		User:    foundUser,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -733,6 +1065,7 @@ func changePasswordHandler(c *gin.Context) {
 
 	// Update password
 	foundUser.Password = string(hashedPassword)
+	foundUser.PasswordChangedAt = time.Now()
 	if err := db.Save(&foundUser).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -751,9 +1084,14 @@ func changePasswordHandler(c *gin.Context) {
 	// Convert userID to uint for logging
 	userIDStr := fmt.Sprintf("%v", userID)
 	if userIDUint, err := strconv.ParseUint(userIDStr, 10, 32); err == nil {
-		logUserActivity(uint(userIDUint), "password_changed", c)
+		logUserActivity(uint(userIDUint), user.ActivityPasswordChanged, nil, c)
 	}
 
+	queueEmail(foundUser.Email, "password_changed_notification", "Your password was changed", email.TemplateData{
+		AppName: cfg.AppName,
+		User:    foundUser,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Password changed successfully",
@@ -764,9 +1102,7 @@ func verifyEmailHandler(c *gin.Context) {
 	// Get token from query parameter or request body
 	token := c.Query("token")
 	if token == "" {
-		var req struct {
-			Token string `json:"token" binding:"required"`
-		}
+		var req auth.ConfirmEmailRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
@@ -778,9 +1114,18 @@ func verifyEmailHandler(c *gin.Context) {
 		token = req.Token
 	}
 
-	// Find verification record with the email token
+	userToken, err := consumeUserToken(token, user.TokenKindEmailConfirm)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid or expired email verification token",
+		})
+		return
+	}
+
+	// Find verification record
 	var verification user.UserVerification
-	if err := db.Where("email_token = ?", token).First(&verification).Error; err != nil {
+	if err := db.Where("user_id = ?", userToken.UserID).First(&verification).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "Invalid email verification token",
@@ -810,7 +1155,6 @@ func verifyEmailHandler(c *gin.Context) {
 	// Mark email as verified
 	verification.EmailVerified = true
 	verification.VerifiedAt = time.Now()
-	verification.EmailToken = "" // Clear the token
 	if err := db.Save(&verification).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -820,7 +1164,7 @@ func verifyEmailHandler(c *gin.Context) {
 	}
 
 	// Log activity
-	logUserActivity(foundUser.ID, "email_verified", c)
+	logUserActivity(foundUser.ID, "email_verified", nil, c)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -877,16 +1221,24 @@ func resendVerificationHandler(c *gin.Context) {
 		return
 	}
 
-	// Generate new verification token
-	newToken := generateRandomToken()
-	verification.EmailToken = newToken
-	db.Save(&verification)
+	// Generate a new verification token
+	newToken, _, err := issueUserToken(fmt.Sprintf("%d", foundUser.ID), user.TokenKindEmailConfirm, 24*time.Hour, "", c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to create verification token",
+		})
+		return
+	}
 
 	// Log activity
-	logUserActivity(foundUser.ID, "verification_email_resent", c)
+	logUserActivity(foundUser.ID, "verification_email_resent", nil, c)
 
-	// TODO: Send verification email
-	log.Printf("Email verification token for %s: %s", foundUser.Email, newToken)
+	queueEmail(foundUser.Email, "verify_email", "Verify your email address", email.TemplateData{
+		AppName:   cfg.AppName,
+		ActionURL: fmt.Sprintf("%s/api/v1/auth/verify-email?token=%s", cfg.OAuthRedirectBaseURL, newToken),
+		User:      foundUser,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -894,137 +1246,1850 @@ func resendVerificationHandler(c *gin.Context) {
 	})
 }
 
-func healthHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data": gin.H{
-			"status":    "healthy",
-			"service":   "auth-service",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"version":   "1.0.0",
-			"database":  "connected",
-		},
-	})
+// emailChangePayload is the JSON stored in a TokenKindEmailChange
+// UserToken's Payload, carrying the new address through to confirmation.
+type emailChangePayload struct {
+	NewEmail string `json:"new_email"`
 }
 
-// Middleware
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"error":   "Authorization header required",
-			})
-			c.Abort()
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"error":   "Bearer token required",
-			})
-			c.Abort()
-			return
-		}
+// requestEmailChangeHandler starts an email change for the authenticated
+// user. The address isn't applied until the confirmation link is clicked,
+// so a typo or a stolen session can't silently take over the account.
+func requestEmailChangeHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(cfg.JWTSecret), nil
-		})
+	var req auth.RequestEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"error":   "Invalid or expired token",
-			})
-			c.Abort()
-			return
-		}
+	var existing user.User
+	if err := db.Where("email = ?", req.NewEmail).First(&existing).Error; err == nil {
+		sendErrorResponse(c, http.StatusConflict, "Email is already in use")
+		return
+	}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"error":   "Invalid token claims",
-			})
-			c.Abort()
-			return
-		}
+	var foundUser user.User
+	if err := db.First(&foundUser, userID).Error; err != nil {
+		sendErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
 
-		c.Set("userID", claims["sub"])
-		c.Set("userEmail", claims["email"])
-		c.Set("userRole", claims["role"])
-		c.Next()
+	payload, err := json.Marshal(emailChangePayload{NewEmail: req.NewEmail})
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to create email change token")
+		return
 	}
-}
 
-func RateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
+	changeToken, tokenRow, err := issueUserToken(fmt.Sprintf("%v", userID), user.TokenKindEmailChange, 24*time.Hour, string(payload), c.ClientIP())
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to create email change token")
+		return
+	}
 
-		if !authRateLimiter.IsAllowed(clientIP, limit, window) {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"success":     false,
-				"error":       "Too many requests. Please try again later.",
-				"retry_after": int(window.Seconds()),
-			})
-			c.Abort()
-			return
-		}
+	queueEmail(req.NewEmail, "verify_email", "Confirm your new email address", email.TemplateData{
+		AppName:   cfg.AppName,
+		ActionURL: fmt.Sprintf("%s/confirm-email-change?token=%s", cfg.OAuthRedirectBaseURL, changeToken),
+		ExpiresAt: tokenRow.ExpiresAt,
+		User:      foundUser,
+	})
 
-		c.Next()
-	}
+	sendSuccessResponse(c, http.StatusOK, nil, "Confirmation link sent to the new email address")
 }
 
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+// confirmEmailChangeHandler applies a pending email change after the user
+// clicks the confirmation link sent to their new address.
+func confirmEmailChangeHandler(c *gin.Context) {
+	var req auth.ConfirmEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+	userToken, err := consumeUserToken(req.Token, user.TokenKindEmailChange)
+	if err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid or expired email change token")
+		return
+	}
 
-		c.Next()
+	var payload emailChangePayload
+	if err := json.Unmarshal([]byte(userToken.Payload), &payload); err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to read email change token")
+		return
 	}
-}
 
-func LoggerMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		start := time.Now()
-		c.Next()
-		duration := time.Since(start)
+	var existing user.User
+	if err := db.Where("email = ?", payload.NewEmail).First(&existing).Error; err == nil {
+		sendErrorResponse(c, http.StatusConflict, "Email is already in use")
+		return
+	}
 
-		log.Printf("%s %s %d %v", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), duration)
+	var foundUser user.User
+	if err := db.First(&foundUser, userToken.UserID).Error; err != nil {
+		sendErrorResponse(c, http.StatusNotFound, "User not found")
+		return
 	}
-}
 
-// Helper functions
-func generateJWTToken(user user.User) (string, time.Time, error) {
+	foundUser.Email = payload.NewEmail
+	if err := db.Save(&foundUser).Error; err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to update email")
+		return
+	}
+
+	logUserActivity(foundUser.ID, user.ActivityEmailChanged, nil, c)
+
+	sendSuccessResponse(c, http.StatusOK, gin.H{"email": foundUser.Email}, "Email address updated")
+}
+
+// acceptTermsHandler records the authenticated user's acceptance of a
+// specific TermsOfService version, clearing LoginResponse's
+// TermsAcceptanceRequired on the client's next login.
+func acceptTermsHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req auth.AcceptTermsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+
+	var terms user.TermsOfService
+	if err := db.Where("version = ?", req.Version).First(&terms).Error; err != nil {
+		sendErrorResponse(c, http.StatusNotFound, "Unknown terms of service version")
+		return
+	}
+
+	userIDStr := fmt.Sprintf("%v", userID)
+	if hasAcceptedTerms(userIDStr, terms.ID) {
+		sendSuccessResponse(c, http.StatusOK, nil, "Terms already accepted")
+		return
+	}
+
+	if err := db.Create(&user.UserTermsAcceptance{
+		UserID:     userIDStr,
+		TermsID:    terms.ID,
+		AcceptedAt: time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}).Error; err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to record terms acceptance")
+		return
+	}
+
+	sendSuccessResponse(c, http.StatusOK, nil, "Terms accepted")
+}
+
+func inviteUserHandler(c *gin.Context) {
+	var req auth.InviteUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+
+	// Check if a user already exists with this email
+	var existingUser user.User
+	if err := db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+		sendErrorResponse(c, http.StatusConflict, "User already exists with this email")
+		return
+	}
+
+	// Invalidate any previous pending invitation for this email
+	db.Model(&user.UserInvitation{}).
+		Where("email = ? AND is_active = ?", req.Email, true).
+		Update("is_active", false)
+
+	invitedBy, _ := c.Get("userID")
+	invitation := user.UserInvitation{
+		Email:       req.Email,
+		Role:        req.Role,
+		InvitedByID: fmt.Sprintf("%v", invitedBy),
+		Token:       generateRandomToken(),
+		ExpiresAt:   time.Now().Add(7 * 24 * time.Hour),
+	}
+
+	if err := db.Create(&invitation).Error; err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to create invitation", err.Error())
+		return
+	}
+
+	queueEmail(invitation.Email, "invitation", fmt.Sprintf("You've been invited to join %s", cfg.AppName), email.TemplateData{
+		AppName:   cfg.AppName,
+		ActionURL: fmt.Sprintf("%s/accept-invitation?token=%s", cfg.OAuthRedirectBaseURL, invitation.Token),
+		ExpiresAt: invitation.ExpiresAt,
+	})
+
+	sendSuccessResponse(c, http.StatusCreated, gin.H{
+		"email":      invitation.Email,
+		"role":       invitation.Role,
+		"expires_at": invitation.ExpiresAt,
+	}, "Invitation sent successfully")
+}
+
+// adminRevokeUserHandler forcibly signs a user out everywhere: every active
+// session is deactivated and its cached state dropped on this replica, and
+// the user's token epoch is bumped so access tokens carrying the old epoch
+// are rejected. Another replica that cached a session's state (epoch
+// included) before the revocation keeps accepting its token for up to
+// sessionCacheTTL.
+func adminRevokeUserHandler(c *gin.Context) {
+	targetID := c.Param("id")
+
+	var targetUser user.User
+	if err := db.First(&targetUser, targetID).Error; err != nil {
+		sendErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	var sessions []user.UserSession
+	db.Where("user_id = ? AND is_active = ?", targetID, true).Find(&sessions)
+
+	db.Model(&user.UserSession{}).
+		Where("user_id = ? AND is_active = ?", targetID, true).
+		Update("is_active", false)
+
+	db.Model(&targetUser).Update("token_epoch", targetUser.TokenEpoch+1)
+
+	for _, session := range sessions {
+		invalidateSessionCache(session.ID)
+	}
+
+	logUserActivity(targetUser.ID, user.ActivitySessionRevoked, user.SessionRevokedPayload{
+		ByAdmin: true,
+	}, c)
+
+	sendSuccessResponse(c, http.StatusOK, nil, "User sessions revoked")
+}
+
+func acceptInvitationHandler(c *gin.Context) {
+	var req auth.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+
+	if req.Password != req.ConfirmPassword {
+		sendErrorResponse(c, http.StatusBadRequest, "Password confirmation does not match")
+		return
+	}
+
+	if err := validatePasswordStrength(req.Password); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var invitation user.UserInvitation
+	if err := db.Where("token = ? AND is_active = ?", req.Token, true).First(&invitation).Error; err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid or expired invitation")
+		return
+	}
+	if !invitation.IsPending() {
+		sendErrorResponse(c, http.StatusBadRequest, "Invitation has expired")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	newUser := user.User{
+		Email:     invitation.Email,
+		Password:  string(hashedPassword),
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Role:      invitation.Role,
+		IsActive:  true,
+	}
+	if err := db.Create(&newUser).Error; err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to create user", err.Error())
+		return
+	}
+
+	// The invitation already proved ownership of the mailbox, so email
+	// verification is satisfied as part of acceptance.
+	verification := user.UserVerification{
+		UserID:        fmt.Sprintf("%d", newUser.ID),
+		EmailVerified: true,
+		VerifiedAt:    time.Now(),
+	}
+	db.Create(&verification)
+
+	invitation.IsActive = false
+	invitation.AcceptedAt = time.Now()
+	db.Save(&invitation)
+
+	token, expiresAt, refreshSession, err := issueTokens(newUser, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate tokens")
+		return
+	}
+
+	logUserActivity(newUser.ID, "invitation_accepted", nil, c)
+
+	loginResponse := auth.LoginResponse{
+		AccessToken:  token,
+		RefreshToken: refreshSession.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(expiresAt).Seconds()),
+		ExpiresAt:    expiresAt,
+		User:         newUser,
+	}
+
+	setAuthCookies(c, token, expiresAt, refreshSession.Token, refreshSession.ExpiresAt)
+	sendSuccessResponse(c, http.StatusCreated, loginResponse, "Invitation accepted successfully")
+}
+
+func mfaEnrollHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	userIDStr := fmt.Sprintf("%v", userID)
+
+	var foundUser user.User
+	if err := db.First(&foundUser, userID).Error; err != nil {
+		sendErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      "Pumpkin Auth Service",
+		AccountName: foundUser.Email,
+	})
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate MFA secret")
+		return
+	}
+
+	// Re-enrolling replaces any previous, unconfirmed secret and recovery
+	// codes rather than leaving stale ones active.
+	var mfa user.UserMFA
+	if err := db.Where("user_id = ?", userIDStr).First(&mfa).Error; err == nil {
+		if mfa.Enabled {
+			sendErrorResponse(c, http.StatusConflict, "MFA is already enabled")
+			return
+		}
+		mfa.Method = user.MFAMethodTOTP
+		mfa.Secret = user.EncryptedField(key.Secret())
+		mfa.Enabled = false
+		db.Save(&mfa)
+	} else {
+		mfa = user.UserMFA{UserID: userIDStr, Method: user.MFAMethodTOTP, Secret: user.EncryptedField(key.Secret())}
+		db.Create(&mfa)
+	}
+
+	db.Where("user_id = ?", userIDStr).Delete(&user.UserRecoveryCode{})
+
+	recoveryCodes := generateRecoveryCodes(10)
+	for _, code := range recoveryCodes {
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate recovery codes")
+			return
+		}
+		db.Create(&user.UserRecoveryCode{UserID: userIDStr, CodeHash: string(hash)})
+	}
+
+	qrCodeDataURL, err := mfaQRCodeDataURL(key)
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate QR code")
+		return
+	}
+
+	sendSuccessResponse(c, http.StatusOK, auth.MFAEnrollResponse{
+		Secret:        key.Secret(),
+		OTPAuthURL:    key.URL(),
+		QRCodeDataURL: qrCodeDataURL,
+		RecoveryCodes: recoveryCodes,
+	}, "Scan the QR code and verify a code to finish enabling MFA")
+}
+
+// mfaQRCodeDataURL renders key's provisioning URI as a PNG QR code and
+// returns it as a data: URL the client can drop straight into an <img> tag.
+func mfaQRCodeDataURL(key *otp.Key) (string, error) {
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// totpPeriod and totpSkew match totp.Validate's defaults (the ones used to
+// enroll in mfaEnrollHandler): a 30-second step, checked one step either
+// side of now to tolerate clock drift.
+const (
+	totpPeriod = 30
+	totpSkew   = 1
+)
+
+// verifyTOTPCode validates code against mfa's secret and rejects any code
+// whose step isn't strictly after the last one mfa accepted, so a captured
+// code can't be reused anywhere in its validity window — including the
+// adjacent step that totp.Validate's skew tolerance also accepts. It can't
+// use totp.Validate directly since that only reports a bool, not which
+// step matched, so it walks the same candidate steps by hand via
+// hotp.ValidateCustom. On success it persists the accepted step as
+// mfa.LastUsedAt.
+func verifyTOTPCode(mfa *user.UserMFA, code string) bool {
+	counter := uint64(time.Now().Unix() / totpPeriod)
+	var lastCounter uint64
+	if !mfa.LastUsedAt.IsZero() {
+		lastCounter = uint64(mfa.LastUsedAt.Unix() / totpPeriod)
+	}
+
+	candidates := []uint64{counter}
+	for i := uint64(1); i <= totpSkew; i++ {
+		candidates = append(candidates, counter+i, counter-i)
+	}
+
+	for _, step := range candidates {
+		valid, err := hotp.ValidateCustom(code, step, string(mfa.Secret), hotp.ValidateOpts{
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil || !valid {
+			continue
+		}
+		if !mfa.LastUsedAt.IsZero() && step <= lastCounter {
+			return false
+		}
+
+		mfa.LastUsedAt = time.Unix(int64(step)*totpPeriod, 0)
+		db.Save(mfa)
+		return true
+	}
+
+	return false
+}
+
+func mfaVerifyHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req auth.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+
+	var mfa user.UserMFA
+	if err := db.Where("user_id = ?", fmt.Sprintf("%v", userID)).First(&mfa).Error; err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "MFA has not been enrolled")
+		return
+	}
+
+	if !verifyTOTPCode(&mfa, req.Code) {
+		sendErrorResponse(c, http.StatusUnauthorized, "Invalid MFA code")
+		return
+	}
+
+	mfa.Enabled = true
+	mfa.EnabledAt = time.Now()
+	if err := db.Save(&mfa).Error; err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to enable MFA")
+		return
+	}
+
+	if userIDUint, err := strconv.ParseUint(fmt.Sprintf("%v", userID), 10, 32); err == nil {
+		logUserActivity(uint(userIDUint), user.ActivityMFAEnrolled, nil, c)
+	}
+
+	sendSuccessResponse(c, http.StatusOK, nil, "MFA enabled successfully")
+}
+
+func mfaDisableHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req auth.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+
+	var mfa user.UserMFA
+	if err := db.Where("user_id = ? AND enabled = ?", fmt.Sprintf("%v", userID), true).First(&mfa).Error; err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "MFA is not enabled")
+		return
+	}
+
+	if !verifyTOTPCode(&mfa, req.Code) {
+		sendErrorResponse(c, http.StatusUnauthorized, "Invalid MFA code")
+		return
+	}
+
+	db.Delete(&mfa)
+	db.Where("user_id = ?", fmt.Sprintf("%v", userID)).Delete(&user.UserRecoveryCode{})
+
+	sendSuccessResponse(c, http.StatusOK, nil, "MFA disabled successfully")
+}
+
+func mfaLoginVerifyHandler(c *gin.Context) {
+	var req auth.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+
+	userID, err := parseMFAToken(req.MFAToken)
+	if err != nil {
+		sendErrorResponse(c, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	var foundUser user.User
+	if err := db.First(&foundUser, userID).Error; err != nil {
+		sendErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	var mfa user.UserMFA
+	if err := db.Where("user_id = ? AND enabled = ?", fmt.Sprintf("%d", userID), true).First(&mfa).Error; err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "MFA is not enabled for this account")
+		return
+	}
+
+	switch {
+	case req.Code != "":
+		if !verifyTOTPCode(&mfa, req.Code) {
+			logUserActivity(foundUser.ID, user.ActivityMFAChallengeFailed, user.MFAChallengeFailedPayload{
+				Reason: "invalid_totp_code",
+			}, c)
+			sendErrorResponse(c, http.StatusUnauthorized, "Invalid MFA code")
+			return
+		}
+	case req.RecoveryCode != "":
+		if !redeemRecoveryCode(userID, req.RecoveryCode) {
+			logUserActivity(foundUser.ID, user.ActivityMFAChallengeFailed, user.MFAChallengeFailedPayload{
+				Reason: "invalid_recovery_code",
+			}, c)
+			sendErrorResponse(c, http.StatusUnauthorized, "Invalid or already used recovery code")
+			return
+		}
+	default:
+		sendErrorResponse(c, http.StatusBadRequest, "code or recovery_code is required")
+		return
+	}
+
+	token, expiresAt, refreshSession, err := issueTokens(foundUser, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate tokens")
+		return
+	}
+
+	foundUser.LastLogin = time.Now()
+	db.Save(&foundUser)
+
+	logUserActivity(foundUser.ID, "mfa_login_verified", nil, c)
+
+	setAuthCookies(c, token, expiresAt, refreshSession.Token, refreshSession.ExpiresAt)
+	sendSuccessResponse(c, http.StatusOK, auth.LoginResponse{
+		AccessToken:  token,
+		RefreshToken: refreshSession.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(expiresAt).Seconds()),
+		ExpiresAt:    expiresAt,
+		User:         foundUser,
+	}, "Login successful")
+}
+
+// reauthenticateHandler verifies the current password (or TOTP code, when
+// MFA is enabled) of an already logged-in user and issues a short-lived,
+// one-time reauth token to be redeemed via the X-Reauth-Token header on a
+// subsequent sensitive operation.
+func reauthenticateHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		sendErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req auth.ReauthenticateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+
+	var foundUser user.User
+	if err := db.First(&foundUser, userID).Error; err != nil {
+		sendErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	var mfa user.UserMFA
+	mfaEnabled := db.Where("user_id = ? AND enabled = ?", fmt.Sprintf("%v", userID), true).First(&mfa).Error == nil
+
+	switch {
+	case mfaEnabled:
+		if req.Code == "" || !verifyTOTPCode(&mfa, req.Code) {
+			sendErrorResponse(c, http.StatusUnauthorized, "Invalid MFA code")
+			return
+		}
+	default:
+		if req.Password == "" || bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(req.Password)) != nil {
+			sendErrorResponse(c, http.StatusUnauthorized, "Invalid password")
+			return
+		}
+	}
+
+	token, expiresAt, err := generateReauthToken(foundUser.ID)
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate reauth token")
+		return
+	}
+
+	sendSuccessResponse(c, http.StatusOK, auth.ReauthenticateResponse{
+		ReauthToken: token,
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+	}, "Reauthentication successful")
+}
+
+// generateReauthToken issues a short-lived, one-time token proving the
+// caller just re-proved their password or MFA code, to be redeemed via the
+// X-Reauth-Token header on a sensitive operation.
+func generateReauthToken(userID uint) (string, time.Time, error) {
+	expiresAt := time.Now().Add(5 * time.Minute)
+
+	claims := jwt.MapClaims{
+		"sub":     userID,
+		"purpose": "reauth",
+		"jti":     generateRandomToken(),
+		"exp":     expiresAt.Unix(),
+		"iat":     time.Now().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
+	return tokenString, expiresAt, err
+}
+
+// parseReauthToken validates a reauth token and returns the user ID, jti,
+// and issue time it carries, without consuming it.
+func parseReauthToken(tokenString string) (userID uint, jti string, issuedAt time.Time, err error) {
+	token, parseErr := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(cfg.JWTSecret), nil
+	})
+	if parseErr != nil || !token.Valid {
+		return 0, "", time.Time{}, fmt.Errorf("invalid or expired reauth token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != "reauth" {
+		return 0, "", time.Time{}, fmt.Errorf("invalid reauth token")
+	}
+
+	sub, ok := claims["sub"].(float64)
+	if !ok {
+		return 0, "", time.Time{}, fmt.Errorf("invalid reauth token subject")
+	}
+	jtiClaim, ok := claims["jti"].(string)
+	if !ok || jtiClaim == "" {
+		return 0, "", time.Time{}, fmt.Errorf("invalid reauth token")
+	}
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return 0, "", time.Time{}, fmt.Errorf("invalid reauth token")
+	}
+
+	return uint(sub), jtiClaim, time.Unix(int64(iat), 0), nil
+}
+
+// RequireReauthMiddleware guards a sensitive operation behind a short-lived
+// reauth token obtained from /reauthenticate, consuming it on first use so
+// it cannot be replayed, and rejecting it if the password has changed since
+// it was issued.
+func RequireReauthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := c.GetHeader("X-Reauth-Token")
+		if tokenString == "" {
+			sendErrorResponse(c, http.StatusUnauthorized, "X-Reauth-Token header is required")
+			c.Abort()
+			return
+		}
+
+		tokenUserID, jti, issuedAt, err := parseReauthToken(tokenString)
+		if err != nil {
+			sendErrorResponse(c, http.StatusUnauthorized, "Invalid or expired reauth token")
+			c.Abort()
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		if fmt.Sprintf("%v", userID) != fmt.Sprintf("%d", tokenUserID) {
+			sendErrorResponse(c, http.StatusUnauthorized, "Reauth token does not match authenticated user")
+			c.Abort()
+			return
+		}
+
+		var foundUser user.User
+		if err := db.First(&foundUser, tokenUserID).Error; err != nil {
+			sendErrorResponse(c, http.StatusUnauthorized, "Invalid or expired reauth token")
+			c.Abort()
+			return
+		}
+		if !foundUser.PasswordChangedAt.IsZero() && foundUser.PasswordChangedAt.After(issuedAt) {
+			sendErrorResponse(c, http.StatusUnauthorized, "Reauth token was invalidated by a password change")
+			c.Abort()
+			return
+		}
+
+		used := user.UsedReauthToken{
+			UserID:    fmt.Sprintf("%d", tokenUserID),
+			JTI:       jti,
+			ExpiresAt: issuedAt.Add(5 * time.Minute),
+		}
+		if err := db.Create(&used).Error; err != nil {
+			sendErrorResponse(c, http.StatusUnauthorized, "Reauth token has already been used")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// redeemRecoveryCode checks code against the user's unused recovery codes
+// and marks the first match as used. Codes are hashed at rest, so this
+// necessarily checks each candidate in turn.
+func redeemRecoveryCode(userID uint, code string) bool {
+	var candidates []user.UserRecoveryCode
+	if err := db.Where("user_id = ? AND used_at IS NULL", fmt.Sprintf("%d", userID)).Find(&candidates).Error; err != nil {
+		return false
+	}
+
+	for _, candidate := range candidates {
+		if bcrypt.CompareHashAndPassword([]byte(candidate.CodeHash), []byte(code)) == nil {
+			candidate.UsedAt = time.Now()
+			db.Save(&candidate)
+			return true
+		}
+	}
+	return false
+}
+
+const oauthStateCookie = "oauth_state"
+
+// discordEndpoint is Discord's OAuth2 endpoint. Unlike Google and GitHub,
+// golang.org/x/oauth2/endpoints does not ship one, so it's defined here.
+var discordEndpoint = oauth2.Endpoint{
+	AuthURL:  "https://discord.com/api/oauth2/authorize",
+	TokenURL: "https://discord.com/api/oauth2/token",
+}
+
+// oauthProviderConfig builds the oauth2.Config and profile endpoint for a
+// supported provider name, as found in the :provider route param.
+func oauthProviderConfig(provider string) (*oauth2.Config, string, error) {
+	redirectURL := fmt.Sprintf("%s/api/v1/auth/oauth/%s/callback", cfg.OAuthRedirectBaseURL, provider)
+
+	switch provider {
+	case user.ProviderGoogle:
+		return &oauth2.Config{
+			ClientID:     cfg.GoogleClientID,
+			ClientSecret: cfg.GoogleClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+			Endpoint:     endpoints.Google,
+		}, "https://www.googleapis.com/oauth2/v2/userinfo", nil
+	case user.ProviderGitHub:
+		return &oauth2.Config{
+			ClientID:     cfg.GitHubClientID,
+			ClientSecret: cfg.GitHubClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       []string{"read:user", "user:email"},
+			Endpoint:     endpoints.GitHub,
+		}, "https://api.github.com/user", nil
+	case user.ProviderDiscord:
+		return &oauth2.Config{
+			ClientID:     cfg.DiscordClientID,
+			ClientSecret: cfg.DiscordClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       []string{"identify", "email"},
+			Endpoint:     discordEndpoint,
+		}, "https://discord.com/api/users/@me", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported oauth provider %q", provider)
+	}
+}
+
+func oauthLoginHandler(c *gin.Context) {
+	provider := c.Param("provider")
+	oauthCfg, _, err := oauthProviderConfig(provider)
+	if err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	state := generateRandomToken()
+	c.SetCookie(oauthStateCookie, state, int((10 * time.Minute).Seconds()), "/", "", cfg.Environment == "production", true)
+
+	c.Redirect(http.StatusFound, oauthCfg.AuthCodeURL(state))
+}
+
+func oauthCallbackHandler(c *gin.Context) {
+	provider := c.Param("provider")
+	oauthCfg, userInfoURL, err := oauthProviderConfig(provider)
+	if err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	expectedState, err := c.Cookie(oauthStateCookie)
+	if err != nil || expectedState == "" || c.Query("state") != expectedState {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid OAuth state")
+		return
+	}
+	c.SetCookie(oauthStateCookie, "", -1, "/", "", cfg.Environment == "production", true)
+
+	token, err := oauthCfg.Exchange(c.Request.Context(), c.Query("code"))
+	if err != nil {
+		sendErrorResponse(c, http.StatusUnauthorized, "Failed to exchange OAuth code", err.Error())
+		return
+	}
+
+	providerUserID, email, emailVerified, rawClaims, err := fetchOAuthProfile(c.Request.Context(), oauthCfg.Client(c.Request.Context(), token), userInfoURL)
+	if err != nil {
+		sendErrorResponse(c, http.StatusBadGateway, "Failed to fetch OAuth profile", err.Error())
+		return
+	}
+
+	foundUser, err := findOrCreateUserForIdentity(provider, providerUserID, email, emailVerified, identityTokens{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    token.Expiry,
+		RawClaims:    rawClaims,
+	})
+	if errors.Is(err, ErrIdentityEmailNotVerified) {
+		sendErrorResponse(c, http.StatusConflict, "An account with this email already exists and is not verified; cannot link automatically")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to link OAuth identity", err.Error())
+		return
+	}
+
+	accessToken, expiresAt, refreshSession, err := issueTokens(foundUser, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate tokens")
+		return
+	}
+
+	foundUser.LastLogin = time.Now()
+	db.Save(&foundUser)
+	logUserActivity(foundUser.ID, user.ActivityKind("oauth_login:"+provider), nil, c)
+
+	setAuthCookies(c, accessToken, expiresAt, refreshSession.Token, refreshSession.ExpiresAt)
+	sendSuccessResponse(c, http.StatusOK, auth.LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshSession.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(expiresAt).Seconds()),
+		ExpiresAt:    expiresAt,
+		User:         foundUser,
+	}, "Login successful")
+}
+
+// oidcLoginHandler signs a user in against a provider token the client
+// already obtained directly (e.g. a mobile SDK's native Google/Apple sign-in),
+// as an alternative to the redirect-based flow behind oauthLoginHandler.
+// Trust follows the same model as the callback handler: AccessToken is
+// handed to the provider's own userinfo endpoint, and whatever the
+// provider hands back is what's believed. IDToken is accepted but not
+// independently verified.
+func oidcLoginHandler(c *gin.Context) {
+	var req auth.OIDCLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+
+	_, userInfoURL, err := oauthProviderConfig(req.Provider)
+	if err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	client := oauth2.NewClient(c.Request.Context(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: req.AccessToken}))
+	providerUserID, email, emailVerified, rawClaims, err := fetchOAuthProfile(c.Request.Context(), client, userInfoURL)
+	if err != nil {
+		sendErrorResponse(c, http.StatusBadGateway, "Failed to fetch OIDC profile", err.Error())
+		return
+	}
+
+	foundUser, err := findOrCreateUserForIdentity(req.Provider, providerUserID, email, emailVerified, identityTokens{
+		AccessToken: req.AccessToken,
+		RawClaims:   rawClaims,
+	})
+	if errors.Is(err, ErrIdentityEmailNotVerified) {
+		sendErrorResponse(c, http.StatusConflict, "An account with this email already exists and is not verified; cannot link automatically")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to link OIDC identity", err.Error())
+		return
+	}
+
+	accessToken, expiresAt, refreshSession, err := issueTokens(foundUser, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to generate tokens")
+		return
+	}
+
+	foundUser.LastLogin = time.Now()
+	db.Save(&foundUser)
+	logUserActivity(foundUser.ID, user.ActivityKind("oidc_login:"+req.Provider), nil, c)
+
+	setAuthCookies(c, accessToken, expiresAt, refreshSession.Token, refreshSession.ExpiresAt)
+	sendSuccessResponse(c, http.StatusOK, auth.LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshSession.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(expiresAt).Seconds()),
+		ExpiresAt:    expiresAt,
+		User:         foundUser,
+	}, "Login successful")
+}
+
+// fetchOAuthProfile calls the provider's userinfo endpoint and pulls out a
+// stable provider user ID, an email address and whether the provider
+// itself claims it's verified, plus the raw claims for callers that want
+// to persist them. Providers disagree on field names and on whether "id"
+// is a string or a number, so this decodes into a generic map rather than
+// a per-provider struct. A provider that sends no "email_verified" claim
+// at all is treated as not verified: findOrCreateUserForIdentity must not
+// auto-link to an existing account on an unconfirmed assertion.
+func fetchOAuthProfile(ctx context.Context, client *http.Client, userInfoURL string) (string, string, bool, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, userInfoURL, nil)
+	if err != nil {
+		return "", "", false, "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", false, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", "", false, "", fmt.Errorf("userinfo request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	rawBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", false, "", err
+	}
+
+	var profile user.UserInfoFields
+	if err := json.Unmarshal(rawBody, &profile); err != nil {
+		return "", "", false, "", err
+	}
+
+	id := profile.GetStringFromKeysOrEmpty("id", "sub")
+	if id == "" {
+		if raw, ok := profile["id"]; ok {
+			id = fmt.Sprintf("%v", raw)
+		}
+	}
+	if id == "" {
+		return "", "", false, "", fmt.Errorf("userinfo response missing id")
+	}
+
+	return id, profile.GetString("email"), profile.GetBoolean("email_verified"), string(rawBody), nil
+}
+
+// identityTokens carries the provider tokens and claims fetched alongside a
+// profile, so findOrCreateUserForIdentity can keep a UserIdentity's
+// provider tokens fresh across both the redirect OAuth2 flow and the
+// direct OIDCLoginRequest flow.
+type identityTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	RawClaims    string
+}
+
+// ErrIdentityEmailNotVerified is returned by findOrCreateUserForIdentity
+// when a profile's email matches an existing account but the link can't
+// be trusted: either the provider didn't assert email_verified, or the
+// matching account's own email isn't verified yet. Auto-linking on an
+// unverified match would let anyone who can get a provider to assert a
+// victim's email address sign straight into the victim's account.
+var ErrIdentityEmailNotVerified = errors.New("identity email matches an existing account but is not verified")
+
+// findOrCreateUserForIdentity resolves an OAuth/OIDC profile to a User: an
+// existing UserIdentity wins outright (its tokens and claims are refreshed
+// from this login); otherwise a matching verified email links the provider
+// to that account, provided both the provider and the existing account
+// agree the email is verified (see ErrIdentityEmailNotVerified); otherwise
+// a brand-new account is created, with its email marked verified whenever
+// the provider returned one, whether or not it asserted email_verified.
+func findOrCreateUserForIdentity(provider, providerUserID, email string, emailVerified bool, tokens identityTokens) (user.User, error) {
+	var identity user.UserIdentity
+	if err := db.Where("provider = ? AND provider_user_id = ?", provider, providerUserID).First(&identity).Error; err == nil {
+		identity.Email = email
+		identity.AccessToken = user.EncryptedField(tokens.AccessToken)
+		identity.RefreshToken = user.EncryptedField(tokens.RefreshToken)
+		identity.ExpiresAt = tokens.ExpiresAt
+		identity.RawClaims = tokens.RawClaims
+		db.Save(&identity)
+
+		var existing user.User
+		if err := db.First(&existing, identity.UserID).Error; err != nil {
+			return user.User{}, err
+		}
+		return existing, nil
+	}
+
+	var foundUser user.User
+	if email != "" {
+		if err := db.Where("email = ?", email).First(&foundUser).Error; err == nil {
+			if !emailVerified {
+				return user.User{}, ErrIdentityEmailNotVerified
+			}
+
+			var verification user.UserVerification
+			if err := db.Where("user_id = ?", fmt.Sprintf("%d", foundUser.ID)).First(&verification).Error; err != nil || !verification.EmailVerified {
+				return user.User{}, ErrIdentityEmailNotVerified
+			}
+
+			if err := db.Create(&user.UserIdentity{
+				UserID:         fmt.Sprintf("%d", foundUser.ID),
+				Provider:       provider,
+				ProviderUserID: providerUserID,
+				Email:          email,
+				AccessToken:    user.EncryptedField(tokens.AccessToken),
+				RefreshToken:   user.EncryptedField(tokens.RefreshToken),
+				ExpiresAt:      tokens.ExpiresAt,
+				RawClaims:      tokens.RawClaims,
+			}).Error; err != nil {
+				return user.User{}, err
+			}
+			return foundUser, nil
+		}
+	}
+
+	randomPassword, err := bcrypt.GenerateFromPassword([]byte(generateRandomToken()), bcrypt.DefaultCost)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	foundUser = user.User{
+		Email:    email,
+		Password: string(randomPassword),
+		Role:     user.RoleCustomer,
+		IsActive: true,
+	}
+	if err := db.Create(&foundUser).Error; err != nil {
+		return user.User{}, err
+	}
+
+	db.Create(&user.UserVerification{
+		UserID:        fmt.Sprintf("%d", foundUser.ID),
+		EmailVerified: email != "",
+		VerifiedAt:    time.Now(),
+	})
+	db.Create(&user.UserIdentity{
+		UserID:         fmt.Sprintf("%d", foundUser.ID),
+		Provider:       provider,
+		ProviderUserID: providerUserID,
+		Email:          email,
+		AccessToken:    user.EncryptedField(tokens.AccessToken),
+		RefreshToken:   user.EncryptedField(tokens.RefreshToken),
+		ExpiresAt:      tokens.ExpiresAt,
+		RawClaims:      tokens.RawClaims,
+	})
+
+	return foundUser, nil
+}
+
+// linkIdentityHandler attaches an external provider identity to the
+// already-authenticated caller's account, verifying the profile the same
+// way oidcLoginHandler does. A provider identity already linked to a
+// different account is rejected rather than silently re-pointed.
+func linkIdentityHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req auth.LinkIdentityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+
+	_, userInfoURL, err := oauthProviderConfig(req.Provider)
+	if err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	client := oauth2.NewClient(c.Request.Context(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: req.AccessToken}))
+	providerUserID, email, _, rawClaims, err := fetchOAuthProfile(c.Request.Context(), client, userInfoURL)
+	if err != nil {
+		sendErrorResponse(c, http.StatusBadGateway, "Failed to fetch OIDC profile", err.Error())
+		return
+	}
+
+	userIDStr := fmt.Sprintf("%v", userID)
+
+	var identity user.UserIdentity
+	if err := db.Where("provider = ? AND provider_user_id = ?", req.Provider, providerUserID).First(&identity).Error; err == nil {
+		if identity.UserID != userIDStr {
+			sendErrorResponse(c, http.StatusConflict, "This identity is already linked to another account")
+			return
+		}
+		identity.Email = email
+		identity.AccessToken = user.EncryptedField(req.AccessToken)
+		identity.RawClaims = rawClaims
+		if err := db.Save(&identity).Error; err != nil {
+			sendErrorResponse(c, http.StatusInternalServerError, "Failed to update linked identity")
+			return
+		}
+		sendSuccessResponse(c, http.StatusOK, identity, "Identity refreshed")
+		return
+	}
+
+	identity = user.UserIdentity{
+		UserID:         userIDStr,
+		Provider:       req.Provider,
+		ProviderUserID: providerUserID,
+		Email:          email,
+		AccessToken:    user.EncryptedField(req.AccessToken),
+		RawClaims:      rawClaims,
+	}
+	if err := db.Create(&identity).Error; err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to link identity")
+		return
+	}
+
+	if userIDUint, err := strconv.ParseUint(userIDStr, 10, 32); err == nil {
+		logUserActivity(uint(userIDUint), user.ActivityKind("identity_linked:"+req.Provider), nil, c)
+	}
+
+	sendSuccessResponse(c, http.StatusCreated, identity, "Identity linked")
+}
+
+// unlinkIdentityHandler removes a provider identity from the authenticated
+// caller's account.
+func unlinkIdentityHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req auth.UnlinkIdentityRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	result := db.Where("user_id = ? AND provider = ?", fmt.Sprintf("%v", userID), req.Provider).Delete(&user.UserIdentity{})
+	if result.Error != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to unlink identity")
+		return
+	}
+	if result.RowsAffected == 0 {
+		sendErrorResponse(c, http.StatusNotFound, "No linked identity for that provider")
+		return
+	}
+
+	sendSuccessResponse(c, http.StatusOK, nil, "Identity unlinked")
+}
+
+func healthHandler(c *gin.Context) {
+	databaseStatus := "connected"
+	if err := database.HealthCheck(c.Request.Context(), db); err != nil {
+		databaseStatus = "unreachable"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"status":    "healthy",
+			"service":   "auth-service",
+			"timestamp": time.Now().Format(time.RFC3339),
+			"version":   "1.0.0",
+			"database":  databaseStatus,
+		},
+	})
+}
+
+// rpcPingHandler is a trivial reachability check for the internal RPC
+// surface, letting an operator confirm a shared secret is wired up correctly
+// without hitting any user-facing data.
+func rpcPingHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"pong": true},
+	})
+}
+
+// openIDConfigurationHandler serves a minimal OIDC discovery document so a
+// relying party can locate the JWKS endpoint without it being hardcoded.
+func openIDConfigurationHandler(c *gin.Context) {
+	issuer := cfg.OAuthRedirectBaseURL
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                issuer,
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"userinfo_endpoint":                     issuer + "/api/v1/auth/userinfo",
+		"authorization_endpoint":                issuer + "/api/v1/auth/oauth/:provider/login",
+		"id_token_signing_alg_values_supported": []string{cfg.JWTAlgorithm},
+	})
+}
+
+// jwksHandler publishes every key the signing key provider still accepts,
+// not just the active one, so tokens signed before the most recent
+// rotation keep verifying until they expire.
+func jwksHandler(c *gin.Context) {
+	keys := signingKeyProvider.PublicKeys()
+	jwks := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		jwk, err := key.JWK()
+		if err != nil {
+			log.Printf("jwks: failed to encode key %s: %v", key.KID, err)
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": jwks})
+}
+
+// userinfoHandler returns the claims carried by the caller's access token,
+// in the spirit of the OIDC userinfo endpoint.
+func userinfoHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var foundUser user.User
+	if err := db.First(&foundUser, userID).Error; err != nil {
+		sendErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	sendSuccessResponse(c, http.StatusOK, gin.H{
+		"sub":   fmt.Sprintf("%d", foundUser.ID),
+		"email": foundUser.Email,
+		"role":  foundUser.Role,
+	}, "")
+}
+
+// rotateKeysHandler forces an out-of-schedule key rotation, e.g. after a
+// suspected key compromise. The previous key keeps verifying in-flight
+// tokens until JWTKeyOverlap elapses.
+func rotateKeysHandler(c *gin.Context) {
+	key, err := signingKeyProvider.Rotate()
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to rotate signing keys")
+		return
+	}
+
+	sendSuccessResponse(c, http.StatusOK, gin.H{"kid": key.KID}, "Signing keys rotated")
+}
+
+// Extractor pulls a candidate access token out of a request. Returning ""
+// means "not present here", letting AuthMiddleware fall through to the
+// next extractor in the chain rather than treating absence as invalid.
+type Extractor interface {
+	Extract(c *gin.Context) string
+}
+
+// HeaderExtractor reads a "Bearer <token>" value from the named header.
+type HeaderExtractor struct {
+	Header string
+}
+
+func (e HeaderExtractor) Extract(c *gin.Context) string {
+	value := c.GetHeader(e.Header)
+	if trimmed := strings.TrimPrefix(value, "Bearer "); trimmed != value {
+		return trimmed
+	}
+	return ""
+}
+
+// CookieExtractor reads the named cookie, set by setAuthCookies on login.
+type CookieExtractor struct {
+	Name string
+}
+
+func (e CookieExtractor) Extract(c *gin.Context) string {
+	value, _ := c.Cookie(e.Name)
+	return value
+}
+
+// QueryExtractor reads the named query parameter, for contexts that can't
+// set headers or cookies, e.g. an <img> tag or a WebSocket upgrade request.
+type QueryExtractor struct {
+	Param string
+}
+
+func (e QueryExtractor) Extract(c *gin.Context) string {
+	return c.Query(e.Param)
+}
+
+// tokenExtractors is the default extraction chain for access tokens, tried
+// in order until one yields a JWT-shaped candidate.
+var tokenExtractors = []Extractor{
+	HeaderExtractor{Header: "Authorization"},
+	HeaderExtractor{Header: "X-Auth"},
+	CookieExtractor{Name: "access_token"},
+	QueryExtractor{Param: "access_token"},
+}
+
+// extractToken runs tokenExtractors in order and returns the first
+// non-empty value that looks like a JWT (JWS compact serialization: three
+// dot-separated segments), so a stray cookie or query param holding an
+// opaque refresh token is never mistaken for an access token.
+func extractToken(c *gin.Context) string {
+	for _, extractor := range tokenExtractors {
+		if value := extractor.Extract(c); value != "" && looksLikeJWT(value) {
+			return value
+		}
+	}
+	return ""
+}
+
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// setAuthCookies sets the access and refresh tokens as HttpOnly, Secure
+// (outside development), SameSite=Lax cookies alongside the JSON response,
+// so a browser-based SPA can authenticate purely via cookies without ever
+// touching localStorage.
+func setAuthCookies(c *gin.Context, accessToken string, accessExpiresAt time.Time, refreshToken string, refreshExpiresAt time.Time) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	secure := cfg.Environment != "development"
+	c.SetCookie("access_token", accessToken, int(time.Until(accessExpiresAt).Seconds()), "/", "", secure, true)
+	c.SetCookie("refresh_token", refreshToken, int(time.Until(refreshExpiresAt).Seconds()), "/", "", secure, true)
+}
+
+// Middleware
+// sessionCacheTTL bounds how stale the session-revocation check in
+// AuthMiddleware may be: a revoked session can still authenticate for up to
+// this long on a replica that cached it just before the revocation.
+const sessionCacheTTL = 30 * time.Second
+
+// sessionState is the cached result of checking a session's revocation
+// status and the owning user's token epoch, so AuthMiddleware isn't forced
+// into two DB round-trips on every authenticated request.
+type sessionState struct {
+	active    bool
+	expiresAt time.Time
+	epoch     int
+	fetchedAt time.Time
+}
+
+var (
+	sessionCacheMu sync.Mutex
+	sessionCache   = map[uint]sessionState{}
+)
+
+// lookupSessionState returns the cached state for sessionID, refreshing it
+// from the database once it's older than sessionCacheTTL.
+func lookupSessionState(sessionID uint) (sessionState, error) {
+	sessionCacheMu.Lock()
+	cached, ok := sessionCache[sessionID]
+	sessionCacheMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < sessionCacheTTL {
+		return cached, nil
+	}
+
+	var session user.UserSession
+	if err := db.First(&session, sessionID).Error; err != nil {
+		return sessionState{}, err
+	}
+
+	var owner user.User
+	if err := db.Select("token_epoch").First(&owner, session.UserID).Error; err != nil {
+		return sessionState{}, err
+	}
+
+	state := sessionState{
+		active:    session.IsActive,
+		expiresAt: session.ExpiresAt,
+		epoch:     owner.TokenEpoch,
+		fetchedAt: time.Now(),
+	}
+
+	sessionCacheMu.Lock()
+	sessionCache[sessionID] = state
+	sessionCacheMu.Unlock()
+
+	return state, nil
+}
+
+// invalidateSessionCache drops a session's cached state, used right after a
+// revocation so the next request sees it immediately instead of waiting
+// out sessionCacheTTL.
+func invalidateSessionCache(sessionID uint) {
+	sessionCacheMu.Lock()
+	delete(sessionCache, sessionID)
+	sessionCacheMu.Unlock()
+}
+
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := extractToken(c)
+		if tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Access token required",
+			})
+			c.Abort()
+			return
+		}
+
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			key, ok := signingKeyProvider.LookupKey(kid)
+			if !ok {
+				return nil, fmt.Errorf("unknown signing key: %q", kid)
+			}
+			if token.Method.Alg() != key.SigningMethod().Alg() {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return key.Public(), nil
+		})
+
+		if err != nil || !token.Valid {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Invalid or expired token",
+			})
+			c.Abort()
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Invalid token claims",
+			})
+			c.Abort()
+			return
+		}
+
+		sessionIDClaim, ok := claims["session_id"].(float64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Invalid token claims",
+			})
+			c.Abort()
+			return
+		}
+		sessionID := uint(sessionIDClaim)
+
+		state, err := lookupSessionState(sessionID)
+		if err != nil || !state.active || time.Now().After(state.expiresAt) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Session has been revoked",
+			})
+			c.Abort()
+			return
+		}
+
+		epochClaim, _ := claims["epoch"].(float64)
+		if int(epochClaim) != state.epoch {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Token was invalidated by an account-wide revocation",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", claims["sub"])
+		c.Set("userEmail", claims["email"])
+		c.Set("userRole", claims["role"])
+		c.Set("sessionID", sessionID)
+		c.Next()
+	}
+}
+
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("userRole")
+		if fmt.Sprintf("%v", role) != user.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Admin privileges required",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rpcFreshnessWindow bounds how far a machine-to-machine token's "iat" may
+// drift from the server's clock, in either direction, before it's rejected.
+// There's no expiry claim to track server-side: a token is only ever valid
+// for the instant around when it was minted.
+const rpcFreshnessWindow = 60 * time.Second
+
+// RPCAuthMiddleware authenticates internal service-to-service calls (a
+// background worker, a webhook receiver, a future admin CLI) using a
+// shared-secret HS256 JWT, as a deliberately separate path from the
+// user-facing bearer-token flow handled by AuthMiddleware. Callers mint
+// tokens with GenerateRPCToken; the only claim that matters is "iat".
+// Authenticated requests get rpcCaller=true on the Gin context instead of
+// userID/userEmail, so handlers can tell the two flows apart.
+func RPCAuthMiddleware(secretHex string) gin.HandlerFunc {
+	secret, err := hex.DecodeString(secretHex)
+	if err != nil {
+		log.Fatalf("RPCAuthMiddleware: invalid secretHex: %v", err)
+	}
+
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" || tokenString == c.GetHeader("Authorization") {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "RPC token required",
+			})
+			c.Abort()
+			return
+		}
+
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return secret, nil
+		})
+		if err != nil || !token.Valid {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Invalid RPC token",
+			})
+			c.Abort()
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Invalid RPC token claims",
+			})
+			c.Abort()
+			return
+		}
+
+		iat, ok := claims["iat"].(float64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "RPC token missing iat claim",
+			})
+			c.Abort()
+			return
+		}
+
+		drift := time.Since(time.Unix(int64(iat), 0))
+		if drift < 0 {
+			drift = -drift
+		}
+		if drift > rpcFreshnessWindow {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "RPC token is stale",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("rpcCaller", true)
+		c.Next()
+	}
+}
+
+// GenerateRPCToken mints a fresh machine-to-machine token for an internal
+// caller, signed with the shared secret RPCAuthMiddleware verifies against.
+func GenerateRPCToken(secretHex string) (string, error) {
+	secret, err := hex.DecodeString(secretHex)
+	if err != nil {
+		return "", fmt.Errorf("invalid secretHex: %w", err)
+	}
+
+	claims := jwt.MapClaims{"iat": time.Now().Unix()}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secret)
+}
+
+// RateLimitMiddleware enforces limit requests per window, keyed by the
+// authenticated user ID when available and by client IP otherwise. It sets
+// the standard X-RateLimit-Limit and X-RateLimit-Remaining headers on every
+// response, plus Retry-After once the limit is hit.
+func RateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.ClientIP()
+		if userID, exists := c.Get("userID"); exists {
+			key = fmt.Sprintf("%v", userID)
+		}
+
+		result, err := authLimiter.Allow(c.Request.Context(), key, limit, window)
+		if err != nil {
+			log.Printf("rate limiter error: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Writer.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Writer.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+
+		if !result.Allowed {
+			c.Writer.Header().Set("Retry-After", strconv.Itoa(int(result.RetryAfter.Seconds())))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"success":     false,
+				"error":       "Too many requests. Please try again later.",
+				"retry_after": int(result.RetryAfter.Seconds()),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CORSConfig controls which origins, methods, and headers CORSMiddleware
+// allows, and whether it runs in credentialed mode. AllowedOrigins entries
+// are either exact origins or a "*.example.com" wildcard; AllowOriginFunc,
+// if set, is consulted first for origins that need dynamic logic an
+// allowlist can't express.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowOriginFunc  func(origin string) bool
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// defaultCORSAllowedMethods and defaultCORSAllowedHeaders are applied when
+// CORS_ALLOWED_METHODS/CORS_ALLOWED_HEADERS are unset or empty.
+var (
+	defaultCORSAllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	defaultCORSAllowedHeaders = []string{"Content-Type", "Authorization"}
+)
+
+// corsConfigFromEnv builds the CORSConfig main() wires into the router from
+// the process Config. AllowOriginFunc has no env-tag equivalent and stays
+// nil; it's there for callers who embed CORSMiddleware with extra logic.
+func corsConfigFromEnv(cfg *config.Config) CORSConfig {
+	allowedMethods := cfg.CORSAllowedMethods
+	if len(allowedMethods) == 0 {
+		allowedMethods = defaultCORSAllowedMethods
+	}
+	allowedHeaders := cfg.CORSAllowedHeaders
+	if len(allowedHeaders) == 0 {
+		allowedHeaders = defaultCORSAllowedHeaders
+	}
+
+	return CORSConfig{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   allowedMethods,
+		AllowedHeaders:   allowedHeaders,
+		ExposedHeaders:   cfg.CORSExposedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	}
+}
+
+// allowsOrigin reports whether origin matches one of cc's allowed origins
+// or AllowOriginFunc.
+func (cc CORSConfig) allowsOrigin(origin string) bool {
+	if cc.AllowOriginFunc != nil && cc.AllowOriginFunc(origin) {
+		return true
+	}
+	for _, allowed := range cc.AllowedOrigins {
+		switch {
+		case allowed == "*":
+			return true
+		case allowed == origin:
+			return true
+		case strings.HasPrefix(allowed, "*."):
+			if suffix := strings.TrimPrefix(allowed, "*"); strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasWildcardOrigin reports whether cc allows any origin unconditionally,
+// letting CORSMiddleware echo back "*" instead of the specific origin when
+// credentials aren't in play.
+func (cc CORSConfig) hasWildcardOrigin() bool {
+	for _, allowed := range cc.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSMiddleware enforces cc's CORS policy. Disallowed origins get no CORS
+// headers at all rather than a permissive fallback, preflight responses
+// carry Access-Control-Max-Age so browsers stop re-checking every request,
+// and the allowed origin is echoed back verbatim whenever credentials are
+// enabled, since browsers reject Access-Control-Allow-Origin: * paired with
+// Access-Control-Allow-Credentials: true.
+func CORSMiddleware(cc CORSConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.Request.Header.Get("Origin")
+		if origin == "" || !cc.allowsOrigin(origin) {
+			c.Next()
+			return
+		}
+
+		if cc.AllowCredentials {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			c.Writer.Header().Set("Vary", "Origin")
+		} else if cc.hasWildcardOrigin() {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Vary", "Origin")
+		}
+
+		if len(cc.AllowedMethods) > 0 {
+			c.Writer.Header().Set("Access-Control-Allow-Methods", strings.Join(cc.AllowedMethods, ", "))
+		}
+		if len(cc.AllowedHeaders) > 0 {
+			c.Writer.Header().Set("Access-Control-Allow-Headers", strings.Join(cc.AllowedHeaders, ", "))
+		}
+		if len(cc.ExposedHeaders) > 0 {
+			c.Writer.Header().Set("Access-Control-Expose-Headers", strings.Join(cc.ExposedHeaders, ", "))
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			if cc.MaxAge > 0 {
+				c.Writer.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cc.MaxAge.Seconds())))
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func LoggerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		duration := time.Since(start)
+
+		log.Printf("%s %s %d %v", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), duration)
+	}
+}
+
+// Helper functions
+
+// issueTokens creates a new session and an access token bound to it via the
+// "session_id" claim, so AuthMiddleware can reject the access token the
+// moment the session is revoked instead of waiting out its 15-minute
+// lifetime.
+func issueTokens(u user.User, clientIP, userAgent string) (accessToken string, expiresAt time.Time, session *user.UserSession, err error) {
+	session, err = generateRefreshToken(u.ID, clientIP, userAgent)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	accessToken, expiresAt, err = generateJWTToken(u, session.ID)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	return accessToken, expiresAt, session, nil
+}
+
+func generateJWTToken(user user.User, sessionID uint) (string, time.Time, error) {
 	expiresAt := time.Now().Add(15 * time.Minute) // Short-lived access token (15 minutes)
 
 	claims := jwt.MapClaims{
-		"sub":   user.ID,
-		"email": user.Email,
-		"role":  user.Role,
-		"exp":   expiresAt.Unix(),
-		"iat":   time.Now().Unix(),
+		"sub":        user.ID,
+		"email":      user.Email,
+		"role":       user.Role,
+		"session_id": sessionID,
+		"epoch":      user.TokenEpoch,
+		"exp":        expiresAt.Unix(),
+		"iat":        time.Now().Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
+	key, err := signingKeyProvider.ActiveKey()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.KID
+	tokenString, err := token.SignedString(key.PrivateKey)
 
 	return tokenString, expiresAt, err
 }
 
+// generateMFAToken issues a short-lived token proving the password step of
+// login already succeeded, to be redeemed at /mfa/login-verify.
+func generateMFAToken(userID uint) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":     userID,
+		"purpose": "mfa_pending",
+		"exp":     time.Now().Add(5 * time.Minute).Unix(),
+		"iat":     time.Now().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(cfg.JWTSecret))
+}
+
+// parseMFAToken validates an MFA challenge token and returns the user ID it
+// was issued for.
+func parseMFAToken(tokenString string) (uint, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(cfg.JWTSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, fmt.Errorf("invalid or expired MFA token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != "mfa_pending" {
+		return 0, fmt.Errorf("invalid MFA token")
+	}
+
+	sub, ok := claims["sub"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid MFA token subject")
+	}
+	return uint(sub), nil
+}
+
+// generateRecoveryCodes returns n plaintext recovery codes formatted as
+// XXXX-XXXX for readability; callers are responsible for hashing and
+// storing them, and for showing the plaintext to the user exactly once.
+func generateRecoveryCodes(n int) []string {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		raw := generateRandomToken()[:8]
+		codes = append(codes, strings.ToUpper(raw[:4]+"-"+raw[4:]))
+	}
+	return codes
+}
+
 func generateRefreshToken(userID uint, clientIP, userAgent string) (*user.UserSession, error) {
 	// Generate a secure random token
 	refreshToken := generateRandomToken()
@@ -1046,6 +3111,117 @@ func generateRefreshToken(userID uint, clientIP, userAgent string) (*user.UserSe
 	return session, nil
 }
 
+// parseUserAgent extracts a coarse browser/OS/device label from a raw
+// User-Agent header for display in the session list. It recognizes the
+// handful of substrings that distinguish the common cases rather than
+// attempting full UA parsing, which isn't worth a dependency for a
+// best-effort label.
+func parseUserAgent(ua string) (browser, os, device string) {
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"), strings.Contains(ua, "Opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Safari/") && strings.Contains(ua, "Version/"):
+		browser = "Safari"
+	case ua != "":
+		browser = "Unknown"
+	}
+
+	switch {
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		os = "iOS"
+	case strings.Contains(ua, "Mac OS X"):
+		os = "macOS"
+	case strings.Contains(ua, "Android"):
+		os = "Android"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	case ua != "":
+		os = "Unknown"
+	}
+
+	switch {
+	case strings.Contains(ua, "iPad"), strings.Contains(ua, "Tablet"):
+		device = "Tablet"
+	case strings.Contains(ua, "Mobi"), strings.Contains(ua, "iPhone"), strings.Contains(ua, "Android"):
+		device = "Mobile"
+	case ua != "":
+		device = "Desktop"
+	}
+
+	return browser, os, device
+}
+
+// issueUserToken creates a UserToken of the given kind and returns the
+// plaintext token, shown or delivered to the user exactly once; only its
+// SHA-256 hash is persisted. A best-effort purge of expired tokens runs
+// first so the table doesn't grow unbounded.
+func issueUserToken(userID, kind string, ttl time.Duration, payload, ipAddress string) (string, *user.UserToken, error) {
+	purgeExpiredUserTokens()
+
+	plaintext := generateRandomToken()
+	hash := sha256.Sum256([]byte(plaintext))
+
+	token := &user.UserToken{
+		UserID:    userID,
+		Kind:      kind,
+		TokenHash: hex.EncodeToString(hash[:]),
+		Payload:   payload,
+		ExpiresAt: time.Now().Add(ttl),
+		IPAddress: ipAddress,
+	}
+	if err := db.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+
+	return plaintext, token, nil
+}
+
+// consumeUserToken looks up the UserToken of kind matching plaintext's hash,
+// constant-time-compares the hash to guard against timing side channels,
+// and marks it consumed if it's still valid.
+func consumeUserToken(plaintext, kind string) (*user.UserToken, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+	hashHex := hex.EncodeToString(hash[:])
+
+	var token user.UserToken
+	if err := db.Where("token_hash = ? AND kind = ?", hashHex, kind).First(&token).Error; err != nil {
+		return nil, fmt.Errorf("token not found")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token.TokenHash), []byte(hashHex)) != 1 {
+		return nil, fmt.Errorf("token mismatch")
+	}
+	if token.IsConsumed() {
+		return nil, fmt.Errorf("token already used")
+	}
+	if token.IsExpired() {
+		return nil, fmt.Errorf("token expired")
+	}
+
+	token.ConsumedAt = time.Now()
+	if err := db.Save(&token).Error; err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// purgeExpiredUserTokens deletes UserToken rows past their ExpiresAt,
+// keeping the table bounded without a dedicated cleanup job.
+func purgeExpiredUserTokens() {
+	db.Where("expires_at < ?", time.Now()).Delete(&user.UserToken{})
+}
+
 func generateRandomToken() string {
 	// Generate 32 bytes of random data
 	bytes := make([]byte, 32)
@@ -1056,16 +3232,80 @@ func generateRandomToken() string {
 	return hex.EncodeToString(bytes)
 }
 
-func logUserActivity(userID uint, activity string, c *gin.Context) {
-	metadata, _ := json.Marshal(map[string]interface{}{
+// logUserActivity writes a UserActivity row for kind, with payload
+// (if non-nil) marshaled into its Metadata column, and mirrors it to
+// activitySink.
+func logUserActivity(userID uint, kind user.ActivityKind, payload user.ActivityPayload, c *gin.Context) {
+	metadataFields := map[string]interface{}{
 		"ip_address": c.ClientIP(),
 		"user_agent": c.Request.UserAgent(),
-	})
+	}
+	if payload != nil {
+		if payloadJSON, err := json.Marshal(payload); err == nil {
+			var payloadFields map[string]interface{}
+			if json.Unmarshal(payloadJSON, &payloadFields) == nil {
+				for k, v := range payloadFields {
+					metadataFields[k] = v
+				}
+			}
+		}
+	}
+	metadata, _ := json.Marshal(metadataFields)
 
 	userActivity := user.UserActivity{
-		UserID:   fmt.Sprintf("%d", userID),
-		Activity: activity,
-		Metadata: string(metadata),
+		UserID:    fmt.Sprintf("%d", userID),
+		Activity:  kind,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Metadata:  string(metadata),
 	}
 	db.Create(&userActivity)
+	activitySink.Record(userActivity)
+}
+
+// queryActivities returns a page of userID's UserActivity rows, newest
+// first, optionally filtered by kind and creation time. cursor is the ID
+// of the last row from a previous page (0 for the first page); the
+// returned nextCursor is 0 once there are no more rows.
+func queryActivities(userID string, kinds []user.ActivityKind, since, until time.Time, limit int, cursor uint) ([]user.UserActivity, uint, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	query := db.Where("user_id = ?", userID)
+	if len(kinds) > 0 {
+		query = query.Where("activity IN ?", kinds)
+	}
+	if !since.IsZero() {
+		query = query.Where("created_at >= ?", since)
+	}
+	if !until.IsZero() {
+		query = query.Where("created_at <= ?", until)
+	}
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+
+	var activities []user.UserActivity
+	if err := query.Order("id DESC").Limit(limit).Find(&activities).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var nextCursor uint
+	if len(activities) == limit {
+		nextCursor = activities[len(activities)-1].ID
+	}
+
+	return activities, nextCursor, nil
+}
+
+// countRecentLoginFailures returns how many ActivityLoginFailure events
+// have been recorded for userID in the last hour, for anomaly detection
+// and LoginFailurePayload.AttemptCount.
+func countRecentLoginFailures(userID uint) int {
+	activities, _, err := queryActivities(fmt.Sprintf("%d", userID), []user.ActivityKind{user.ActivityLoginFailure}, time.Now().Add(-time.Hour), time.Time{}, 100, 0)
+	if err != nil {
+		return 0
+	}
+	return len(activities)
 }